@@ -8,6 +8,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 )
 
 // Handler handles Lambda events.
@@ -36,6 +37,20 @@ type Context struct {
 	ClientContext            json.RawMessage `json:"clientContext"`
 	Identity                 Identity        `json:"identity,omitempty"`
 	InvokedFunctionARN       string          `json:"invokedFunctionArn"`
+
+	// DeadlineMs is the time the invocation times out, in milliseconds since
+	// the Unix epoch, when provided by the shim.
+	DeadlineMs int64 `json:"deadlineMs,omitempty"`
+}
+
+// Deadline returns the time the invocation times out, ok is false when the
+// shim did not provide it.
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c == nil || c.DeadlineMs == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, c.DeadlineMs*int64(time.Millisecond)), true
 }
 
 // Identity as defined in: http://docs.aws.amazon.com/mobile/sdkforandroid/developerguide/lambda.html#identity-context
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/tj/assert"
 )
@@ -60,6 +61,23 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestContext_Deadline(t *testing.T) {
+	var ctx *Context
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	ctx = &Context{}
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+
+	err := json.Unmarshal([]byte(`{"deadlineMs": 1500000000123}`), ctx)
+	assert.NoError(t, err)
+
+	d, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1500000000123), d.UnixNano()/int64(time.Millisecond))
+}
+
 func BenchmarkHandler(b *testing.B) {
 	h := HandlerFunc(func(event json.RawMessage, ctx *Context) (interface{}, error) {
 		return nil, nil
@@ -0,0 +1,101 @@
+// Package authorizer provides structs for writing API Gateway Lambda
+// authorizers, and a builder for the IAM policies they return.
+package authorizer
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/apex/go-apex"
+	"github.com/apex/go-apex/proxy"
+)
+
+// ErrUnauthorized is returned by handlers to have API Gateway respond with
+// 401 Unauthorized.
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// TokenEvent represents a TOKEN authorizer event.
+type TokenEvent struct {
+	Type               string `json:"type"`
+	AuthorizationToken string `json:"authorizationToken"`
+	MethodARN          string `json:"methodArn"`
+}
+
+// RequestEvent represents a REQUEST authorizer event of a REST API, or of an
+// HTTP API using payload format 1.0. The request is described as in a proxy
+// event.
+type RequestEvent struct {
+	Type      string `json:"type"`
+	MethodARN string `json:"methodArn"`
+	proxy.Event
+}
+
+// RequestEventV2 represents a REQUEST authorizer event of an HTTP API using
+// payload format 2.0.
+type RequestEventV2 struct {
+	Type           string   `json:"type"`
+	RouteARN       string   `json:"routeArn"`
+	IdentitySource []string `json:"identitySource"`
+	proxy.Event
+}
+
+// Response is the response of a REST API authorizer, or of an HTTP API
+// authorizer using payload format 1.0. Context values must be strings,
+// numbers or booleans.
+type Response struct {
+	PrincipalID        string                 `json:"principalId"`
+	PolicyDocument     Policy                 `json:"policyDocument"`
+	Context            map[string]interface{} `json:"context,omitempty"`
+	UsageIdentifierKey string                 `json:"usageIdentifierKey,omitempty"`
+}
+
+// SimpleResponse is the simple response of an HTTP API authorizer using
+// payload format 2.0.
+type SimpleResponse struct {
+	IsAuthorized bool                   `json:"isAuthorized"`
+	Context      map[string]interface{} `json:"context,omitempty"`
+}
+
+// TokenHandlerFunc unmarshals TOKEN authorizer events before passing control.
+type TokenHandlerFunc func(*TokenEvent, *apex.Context) (*Response, error)
+
+// Handle implements apex.Handler.
+func (h TokenHandlerFunc) Handle(data json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	var event TokenEvent
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	return h(&event, ctx)
+}
+
+// RequestHandlerFunc unmarshals REQUEST authorizer events before passing
+// control.
+type RequestHandlerFunc func(*RequestEvent, *apex.Context) (*Response, error)
+
+// Handle implements apex.Handler.
+func (h RequestHandlerFunc) Handle(data json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	var event RequestEvent
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	return h(&event, ctx)
+}
+
+// SimpleHandlerFunc unmarshals HTTP API REQUEST authorizer events before
+// passing control, for authorizers with simple responses enabled.
+type SimpleHandlerFunc func(*RequestEventV2, *apex.Context) (*SimpleResponse, error)
+
+// Handle implements apex.Handler.
+func (h SimpleHandlerFunc) Handle(data json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	var event RequestEventV2
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	return h(&event, ctx)
+}
@@ -0,0 +1,176 @@
+package authorizer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+const methodARN = "arn:aws:execute-api:us-east-1:123456789012:abc123/prod/GET/users/1"
+
+func TestParseMethodARN(t *testing.T) {
+	a, err := ParseMethodARN(methodARN)
+	assert.NoError(t, err)
+	assert.Equal(t, &MethodARN{
+		Partition: "aws",
+		Region:    "us-east-1",
+		AccountID: "123456789012",
+		APIID:     "abc123",
+		Stage:     "prod",
+		Method:    "GET",
+		Resource:  "/users/1",
+	}, a)
+	assert.Equal(t, methodARN, a.String())
+
+	a, err = ParseMethodARN("arn:aws:execute-api:us-east-1:123456789012:abc123/prod/GET/")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", a.Resource)
+
+	a, err = ParseMethodARN("arn:aws:execute-api:us-east-1:123456789012:abc123/$default/GET")
+	assert.NoError(t, err)
+	assert.Equal(t, "$default", a.Stage)
+	assert.Equal(t, "/", a.Resource)
+
+	_, err = ParseMethodARN("arn:aws:lambda:us-east-1:123456789012:function:auth")
+	assert.Error(t, err)
+
+	_, err = ParseMethodARN("arn:aws:execute-api:us-east-1:123456789012:abc123")
+	assert.Error(t, err)
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := NewBuilder("user|a1b2", methodARN)
+	assert.NoError(t, err)
+
+	b.Allow("get", "/users/*").Allow(All, "/health").Deny("DELETE", All)
+	b.Context["userId"] = "a1b2"
+	b.Context["admin"] = false
+	b.UsageIdentifierKey = "key"
+
+	v, err := json.Marshal(b.Response())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"principalId": "user|a1b2",
+		"policyDocument": {
+			"Version": "2012-10-17",
+			"Statement": [
+				{
+					"Action": "execute-api:Invoke",
+					"Effect": "Allow",
+					"Resource": [
+						"arn:aws:execute-api:us-east-1:123456789012:abc123/prod/GET/users/*",
+						"arn:aws:execute-api:us-east-1:123456789012:abc123/prod/*/health"
+					]
+				},
+				{
+					"Action": "execute-api:Invoke",
+					"Effect": "Deny",
+					"Resource": ["arn:aws:execute-api:us-east-1:123456789012:abc123/prod/DELETE/*"]
+				}
+			]
+		},
+		"context": {"userId": "a1b2", "admin": false},
+		"usageIdentifierKey": "key"
+	}`, string(v))
+}
+
+func TestBuilder_empty(t *testing.T) {
+	b, err := NewBuilder("anonymous", methodARN)
+	assert.NoError(t, err)
+
+	res := b.Response()
+	assert.Nil(t, res.Context)
+	assert.Len(t, res.PolicyDocument.Statement, 1)
+	assert.Equal(t, &Statement{
+		Action:   "execute-api:Invoke",
+		Effect:   "Deny",
+		Resource: []string{"arn:aws:execute-api:us-east-1:123456789012:abc123/prod/*/*"},
+	}, res.PolicyDocument.Statement[0])
+
+	_, err = NewBuilder("anonymous", "invalid")
+	assert.Error(t, err)
+}
+
+func TestTokenHandlerFunc(t *testing.T) {
+	h := TokenHandlerFunc(func(e *TokenEvent, ctx *apex.Context) (*Response, error) {
+		if e.AuthorizationToken != "Bearer secret" {
+			return nil, ErrUnauthorized
+		}
+
+		b, err := NewBuilder("user", e.MethodARN)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.AllowAll().Response(), nil
+	})
+
+	event := `{"type": "TOKEN", "authorizationToken": "Bearer secret", "methodArn": "` + methodARN + `"}`
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Allow", v.(*Response).PolicyDocument.Statement[0].Effect)
+
+	event = `{"type": "TOKEN", "authorizationToken": "Bearer guess", "methodArn": "` + methodARN + `"}`
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.EqualError(t, err, "Unauthorized")
+}
+
+func TestRequestHandlerFunc(t *testing.T) {
+	h := RequestHandlerFunc(func(e *RequestEvent, ctx *apex.Context) (*Response, error) {
+		assert.Equal(t, "REQUEST", e.Type)
+		assert.Equal(t, methodARN, e.MethodARN)
+		assert.Equal(t, "/users/1", e.Path)
+		assert.Equal(t, "key", e.Headers["X-Api-Key"])
+		assert.Equal(t, "prod", e.RequestContext.Stage)
+		assert.Equal(t, "1", e.PathParameters["id"])
+		return &Response{PrincipalID: "user"}, nil
+	})
+
+	event := `{
+		"type": "REQUEST",
+		"methodArn": "` + methodARN + `",
+		"resource": "/users/{id}",
+		"path": "/users/1",
+		"httpMethod": "GET",
+		"headers": {"X-Api-Key": "key"},
+		"pathParameters": {"id": "1"},
+		"requestContext": {"stage": "prod", "requestId": "abc"}
+	}`
+
+	_, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+}
+
+func TestSimpleHandlerFunc(t *testing.T) {
+	h := SimpleHandlerFunc(func(e *RequestEventV2, ctx *apex.Context) (*SimpleResponse, error) {
+		assert.Equal(t, []string{"secret"}, e.IdentitySource)
+		assert.Equal(t, "GET /users/{id}", e.RouteKey)
+		assert.Equal(t, "/users/1", e.RawPath)
+		assert.Equal(t, "GET", e.RequestContext.HTTP.Method)
+
+		return &SimpleResponse{
+			IsAuthorized: e.IdentitySource[0] == "secret",
+			Context:      map[string]interface{}{"user": "a1b2"},
+		}, nil
+	})
+
+	event := `{
+		"version": "2.0",
+		"type": "REQUEST",
+		"routeArn": "arn:aws:execute-api:us-east-1:123456789012:abc123/$default/GET/users/1",
+		"identitySource": ["secret"],
+		"routeKey": "GET /users/{id}",
+		"rawPath": "/users/1",
+		"headers": {"authorization": "secret"},
+		"requestContext": {"http": {"method": "GET", "path": "/users/1"}}
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"isAuthorized": true, "context": {"user": "a1b2"}}`, string(b))
+}
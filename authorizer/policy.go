@@ -0,0 +1,178 @@
+package authorizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// All matches any stage, method or resource.
+const All = "*"
+
+// Policy is an IAM policy document.
+type Policy struct {
+	Version   string       `json:"Version"`
+	Statement []*Statement `json:"Statement"`
+}
+
+// Statement is an IAM policy statement.
+type Statement struct {
+	Action   string   `json:"Action"`
+	Effect   string   `json:"Effect"`
+	Resource []string `json:"Resource"`
+}
+
+// MethodARN is a parsed method or route ARN, such as
+// "arn:aws:execute-api:us-east-1:123456789012:abc123/prod/GET/users/1".
+type MethodARN struct {
+	Partition string
+	Region    string
+	AccountID string
+	APIID     string
+	Stage     string
+	Method    string
+
+	// Resource is the request path, such as "/users/1", or All.
+	Resource string
+}
+
+// ParseMethodARN parses s.
+func ParseMethodARN(s string) (*MethodARN, error) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "execute-api" {
+		return nil, fmt.Errorf("invalid method ARN %q", s)
+	}
+
+	path := strings.SplitN(parts[5], "/", 4)
+	if len(path) < 3 {
+		return nil, fmt.Errorf("invalid method ARN %q", s)
+	}
+
+	a := &MethodARN{
+		Partition: parts[1],
+		Region:    parts[3],
+		AccountID: parts[4],
+		APIID:     path[0],
+		Stage:     path[1],
+		Method:    path[2],
+		Resource:  "/",
+	}
+
+	if len(path) == 4 {
+		a.Resource = "/" + path[3]
+	}
+
+	return a, nil
+}
+
+// String returns the ARN.
+func (a *MethodARN) String() string {
+	resource := strings.TrimPrefix(a.Resource, "/")
+	if a.Resource == All {
+		resource = All
+	}
+
+	return fmt.Sprintf("arn:%s:execute-api:%s:%s:%s/%s/%s/%s",
+		a.Partition, a.Region, a.AccountID, a.APIID, a.Stage, a.Method, resource)
+}
+
+// Builder builds the response of an authorizer, allowing or denying methods
+// of the API and stage of the method ARN it was created with.
+type Builder struct {
+	// PrincipalID identifies the caller.
+	PrincipalID string
+
+	// Context is passed to the integration, values must be strings, numbers
+	// or booleans.
+	Context map[string]interface{}
+
+	// UsageIdentifierKey is the API key of the caller for usage plans.
+	UsageIdentifierKey string
+
+	arn   MethodARN
+	allow []string
+	deny  []string
+}
+
+// NewBuilder returns a builder for the principal and the method ARN of the
+// authorizer event.
+func NewBuilder(principalID, methodARN string) (*Builder, error) {
+	arn, err := ParseMethodARN(methodARN)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Builder{
+		PrincipalID: principalID,
+		Context:     make(map[string]interface{}),
+		arn:         *arn,
+	}, nil
+}
+
+// Allow access to the method and resource, either of which may be All. The
+// resource may end with a wildcard, such as "/users/*".
+func (b *Builder) Allow(method, resource string) *Builder {
+	b.allow = append(b.allow, b.resource(method, resource))
+	return b
+}
+
+// Deny access to the method and resource, see Allow. Denials take
+// precedence over allowed methods.
+func (b *Builder) Deny(method, resource string) *Builder {
+	b.deny = append(b.deny, b.resource(method, resource))
+	return b
+}
+
+// AllowAll allows access to every method and resource of the stage.
+func (b *Builder) AllowAll() *Builder {
+	return b.Allow(All, All)
+}
+
+// DenyAll denies access to every method and resource of the stage.
+func (b *Builder) DenyAll() *Builder {
+	return b.Deny(All, All)
+}
+
+// resource returns the ARN of the method and resource.
+func (b *Builder) resource(method, resource string) string {
+	arn := b.arn
+	arn.Method = strings.ToUpper(method)
+	arn.Resource = resource
+	return arn.String()
+}
+
+// Response returns the authorizer response. Access to everything is denied
+// when no method was allowed or denied.
+func (b *Builder) Response() *Response {
+	res := &Response{
+		PrincipalID:        b.PrincipalID,
+		PolicyDocument:     Policy{Version: "2012-10-17"},
+		UsageIdentifierKey: b.UsageIdentifierKey,
+	}
+
+	if len(b.Context) > 0 {
+		res.Context = b.Context
+	}
+
+	deny := b.deny
+	if len(b.allow) == 0 && len(deny) == 0 {
+		deny = []string{b.resource(All, All)}
+	}
+
+	if len(b.allow) > 0 {
+		res.PolicyDocument.Statement = append(res.PolicyDocument.Statement, &Statement{
+			Action:   "execute-api:Invoke",
+			Effect:   "Allow",
+			Resource: b.allow,
+		})
+	}
+
+	if len(deny) > 0 {
+		res.PolicyDocument.Statement = append(res.PolicyDocument.Statement, &Statement{
+			Action:   "execute-api:Invoke",
+			Effect:   "Deny",
+			Resource: deny,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,165 @@
+// Package batch provides concurrent per-record processing for batch events
+// such as Kinesis, Dynamo, S3 and SNS.
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrSkipped is reported for records which were not processed because an
+// earlier record sharing the same key failed.
+var ErrSkipped = errors.New("skipped after earlier failure with the same key")
+
+// Item is a single record of a batch.
+type Item struct {
+	// ID identifies the record in errors, for example a Kinesis sequence number.
+	ID string
+
+	// Key groups records which must be processed in order, for example a
+	// Kinesis partition key. Records with an empty key are unordered.
+	Key string
+
+	// Record is the original event record.
+	Record interface{}
+}
+
+// Func processes a single item.
+type Func func(Item) error
+
+// Failure represents a single failed record.
+type Failure struct {
+	ID  string
+	Key string
+	Err error
+}
+
+// Error is returned when one or more records failed.
+type Error struct {
+	Total    int
+	Failures []*Failure
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	var msgs []string
+
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f.ID, f.Err))
+	}
+
+	return fmt.Sprintf("%d of %d records failed: %s", len(e.Failures), e.Total, strings.Join(msgs, "; "))
+}
+
+// IDs returns the IDs of the failed records.
+func (e *Error) IDs() []string {
+	ids := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		ids[i] = f.ID
+	}
+	return ids
+}
+
+// Process runs fn for each item with at most concurrency items in flight.
+//
+// Items sharing a key are processed sequentially in their original order,
+// while different keys are processed in parallel. Once an item fails the
+// remaining items with the same key are skipped and reported with ErrSkipped.
+// The returned error is an *Error listing failures in item order, or nil.
+func Process(items []Item, concurrency int, fn Func) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	groups := group(items)
+	errs := make([]error, len(items))
+
+	work := make(chan []int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency && i < len(groups); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range work {
+				run(items, g, fn, errs)
+			}
+		}()
+	}
+
+	for _, g := range groups {
+		work <- g
+	}
+	close(work)
+	wg.Wait()
+
+	var failures []*Failure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, &Failure{
+				ID:  items[i].ID,
+				Key: items[i].Key,
+				Err: err,
+			})
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &Error{
+		Total:    len(items),
+		Failures: failures,
+	}
+}
+
+// run processes a group of item indexes in order.
+func run(items []Item, g []int, fn Func, errs []error) {
+	for n, i := range g {
+		if err := call(fn, items[i]); err != nil {
+			errs[i] = err
+			for _, j := range g[n+1:] {
+				errs[j] = ErrSkipped
+			}
+			return
+		}
+	}
+}
+
+// call invokes fn, converting a panic into an error.
+func call(fn Func, item Item) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+
+	return fn(item)
+}
+
+// group returns item indexes grouped by key in order of first appearance.
+func group(items []Item) [][]int {
+	var groups [][]int
+	keys := make(map[string]int)
+
+	for i, item := range items {
+		if item.Key == "" {
+			groups = append(groups, []int{i})
+			continue
+		}
+
+		n, ok := keys[item.Key]
+		if !ok {
+			n = len(groups)
+			keys[item.Key] = n
+			groups = append(groups, nil)
+		}
+
+		groups[n] = append(groups[n], i)
+	}
+
+	return groups
+}
@@ -0,0 +1,110 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcess_order(t *testing.T) {
+	var items []Item
+	for i := 0; i < 30; i++ {
+		items = append(items, Item{
+			ID:     fmt.Sprint(i),
+			Key:    fmt.Sprint(i % 3),
+			Record: i,
+		})
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+
+	err := Process(items, 4, func(item Item) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		seen[item.Key] = append(seen[item.Key], item.Record.(int))
+		mu.Unlock()
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 3, 6, 9, 12, 15, 18, 21, 24, 27}, seen["0"])
+	assert.Equal(t, []int{1, 4, 7, 10, 13, 16, 19, 22, 25, 28}, seen["1"])
+	assert.Equal(t, []int{2, 5, 8, 11, 14, 17, 20, 23, 26, 29}, seen["2"])
+}
+
+func TestProcess_concurrency(t *testing.T) {
+	var items []Item
+	for i := 0; i < 20; i++ {
+		items = append(items, Item{ID: fmt.Sprint(i)})
+	}
+
+	var active, max int32
+
+	err := Process(items, 3, func(item Item) error {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, max <= 3, "at most 3 items in flight")
+	assert.True(t, max > 1, "items processed in parallel")
+}
+
+func TestProcess_errors(t *testing.T) {
+	items := []Item{
+		{ID: "a1", Key: "a"},
+		{ID: "b1", Key: "b"},
+		{ID: "a2", Key: "a"},
+		{ID: "b2", Key: "b"},
+		{ID: "a3", Key: "a"},
+		{ID: "c1"},
+	}
+
+	var mu sync.Mutex
+	var called []string
+
+	err := Process(items, 2, func(item Item) error {
+		mu.Lock()
+		called = append(called, item.ID)
+		mu.Unlock()
+
+		switch item.ID {
+		case "a2":
+			return errors.New("boom")
+		case "c1":
+			panic("oh no")
+		}
+		return nil
+	})
+
+	assert.NotContains(t, called, "a3")
+
+	e, ok := err.(*Error)
+	assert.True(t, ok, "error is *Error")
+	assert.Equal(t, 6, e.Total)
+	assert.Equal(t, []string{"a2", "a3", "c1"}, e.IDs())
+	assert.EqualError(t, e.Failures[0].Err, "boom")
+	assert.Equal(t, ErrSkipped, e.Failures[1].Err)
+	assert.EqualError(t, e.Failures[2].Err, "panic: oh no")
+	assert.Equal(t, "3 of 6 records failed: a2: boom; a3: skipped after earlier failure with the same key; c1: panic: oh no", e.Error())
+}
+
+func TestProcess_empty(t *testing.T) {
+	assert.NoError(t, Process(nil, 0, func(Item) error {
+		return errors.New("not called")
+	}))
+}
@@ -3,8 +3,11 @@ package dynamo
 
 import (
 	"encoding/json"
+	"sort"
+	"strings"
 
 	"github.com/apex/go-apex"
+	"github.com/apex/go-apex/batch"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -41,6 +44,41 @@ type StreamRecord struct {
 	StreamViewType              string
 }
 
+// Items returns the records as batch items keyed by item key, so that
+// changes to the same item are processed in order.
+func (e *Event) Items() []batch.Item {
+	items := make([]batch.Item, len(e.Records))
+	for i, r := range e.Records {
+		item := batch.Item{
+			ID:     r.EventID,
+			Record: r,
+		}
+		if r.Dynamodb != nil {
+			item.Key = itemKey(r.Dynamodb.Keys)
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// itemKey returns a stable string representation of the given item keys,
+// skipping nil attribute values.
+func itemKey(keys map[string]*dynamodb.AttributeValue) string {
+	var names []string
+	for name, v := range keys {
+		if v != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, name+"="+keys[name].String())
+	}
+	return strings.Join(parts, ",")
+}
+
 // Handler handles Dynamo events.
 type Handler interface {
 	HandleDynamo(*Event, *apex.Context) error
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/apex/go-apex"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,3 +17,34 @@ func Test(t *testing.T) {
 	assert.Nil(t, nil)
 	// TODO: unmarshalling test
 }
+
+func TestEvent_Items(t *testing.T) {
+	id := func(s string) *dynamodb.AttributeValue { return (&dynamodb.AttributeValue{}).SetS(s) }
+	n := func(s string) *dynamodb.AttributeValue { return (&dynamodb.AttributeValue{}).SetN(s) }
+
+	cases := []struct {
+		keys map[string]*dynamodb.AttributeValue
+		key  string
+	}{
+		{map[string]*dynamodb.AttributeValue{"id": id("1")}, "id=" + id("1").String()},
+		{map[string]*dynamodb.AttributeValue{"sort": n("2"), "id": id("1")}, "id=" + id("1").String() + ",sort=" + n("2").String()},
+		{map[string]*dynamodb.AttributeValue{"id": id("1"), "sort": nil}, "id=" + id("1").String()},
+		{nil, ""},
+	}
+
+	for i, c := range cases {
+		e := &Event{Records: []*Record{
+			{EventID: "a", Dynamodb: &StreamRecord{Keys: c.keys}},
+			{EventID: "b", Dynamodb: &StreamRecord{Keys: c.keys}},
+			{EventID: "c"},
+		}}
+
+		items := e.Items()
+		assert.Len(t, items, 3)
+		assert.Equal(t, "a", items[0].ID)
+		assert.Equal(t, c.key, items[0].Key, "case %d", i)
+		assert.Equal(t, items[0].Key, items[1].Key, "case %d", i)
+		assert.Equal(t, "", items[2].Key)
+		assert.Equal(t, e.Records[1], items[1].Record)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 
 	"github.com/apex/go-apex"
+	"github.com/apex/go-apex/batch"
 )
 
 // Event represents a Kinesis event with one or more records.
@@ -29,6 +30,20 @@ type Record struct {
 	}
 }
 
+// Items returns the records as batch items keyed by partition key, so that
+// records of the same partition are processed in order.
+func (e *Event) Items() []batch.Item {
+	items := make([]batch.Item, len(e.Records))
+	for i, r := range e.Records {
+		items[i] = batch.Item{
+			ID:     r.Kinesis.SequenceNumber,
+			Key:    r.Kinesis.PartitionKey,
+			Record: r,
+		}
+	}
+	return items
+}
+
 // Handler handles Kinesis events.
 type Handler interface {
 	HandleKinesis(*Event, *apex.Context) error
@@ -16,3 +16,31 @@ func Test(t *testing.T) {
 	assert.Nil(t, nil)
 	// TODO: unmarshalling test
 }
+
+func TestEvent_Items(t *testing.T) {
+	cases := []struct {
+		partition string
+		sequence  string
+	}{
+		{"user-1", "100"},
+		{"user-2", "101"},
+		{"user-1", "102"},
+		{"", "103"},
+	}
+
+	var e Event
+	for _, c := range cases {
+		r := &Record{}
+		r.Kinesis.PartitionKey = c.partition
+		r.Kinesis.SequenceNumber = c.sequence
+		e.Records = append(e.Records, r)
+	}
+
+	items := e.Items()
+	assert.Len(t, items, len(cases))
+	for i, c := range cases {
+		assert.Equal(t, c.sequence, items[i].ID)
+		assert.Equal(t, c.partition, items[i].Key)
+		assert.Equal(t, e.Records[i], items[i].Record)
+	}
+}
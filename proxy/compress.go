@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultCompressMinSize is the default Options.CompressMinSize.
+const DefaultCompressMinSize = 1024
+
+// Encoder returns a writer compressing to w with a content coding, such as
+// brotli.NewWriter of a brotli package for "br".
+type Encoder func(w io.Writer) io.WriteCloser
+
+// encoders returns the available encoders by content coding.
+func (o *options) encoders() map[string]Encoder {
+	encoders := map[string]Encoder{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+	}
+
+	for k, e := range o.Encoders {
+		encoders[strings.ToLower(k)] = e
+	}
+
+	return encoders
+}
+
+// compress compresses the output when enabled, the output is text of at
+// least the minimum size, and the client accepts one of the encodings.
+func (w *ResponseWriter) compress() {
+	if w.options == nil || !w.options.Compress || !w.headersWritten {
+		return
+	}
+
+	if w.Header().Get("Content-Encoding") != "" || w.isBinary() {
+		return
+	}
+
+	minSize := w.options.CompressMinSize
+	if minSize == 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	if w.output.Len() < minSize {
+		return
+	}
+
+	// Caches must not serve the response to clients accepting other encodings
+	w.addVary("Accept-Encoding")
+
+	encoders := w.options.encoders()
+	coding := negotiateEncoding(w.acceptEncoding, encoders)
+	if coding == "" {
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := encoders[coding](&buf)
+	if _, err := enc.Write(w.output.Bytes()); err != nil {
+		return
+	}
+	if err := enc.Close(); err != nil {
+		return
+	}
+
+	w.output.Reset()
+	w.output.Write(buf.Bytes())
+	w.setHeader("Content-Encoding", coding)
+	w.setHeader("Content-Length")
+}
+
+// addVary adds field to the Vary header of the written response.
+func (w *ResponseWriter) addVary(field string) {
+	addVary(w.Header(), field)
+	w.setHeader("Vary", w.Header()["Vary"]...)
+}
+
+// addVary adds field to the Vary header of h, joining its values.
+func addVary(h http.Header, field string) {
+	var values []string
+
+	for _, v := range h["Vary"] {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+			if f != "" {
+				values = append(values, f)
+			}
+		}
+	}
+
+	h.Set("Vary", strings.Join(append(values, field), ", "))
+}
+
+// setHeader sets the header key of the written response, or deletes it
+// without values.
+func (w *ResponseWriter) setHeader(key string, values ...string) {
+	key = http.CanonicalHeaderKey(key)
+
+	if len(values) == 0 {
+		w.Header().Del(key)
+		delete(w.response.Headers, key)
+		delete(w.response.MultiValueHeaders, key)
+		return
+	}
+
+	w.Header()[key] = values
+	w.response.Headers[key] = values[len(values)-1]
+	w.response.MultiValueHeaders[key] = values
+}
+
+// negotiateEncoding returns the encoding with the highest quality value in
+// the Accept-Encoding header, preferring "br" and then "gzip" among equals,
+// or an empty string when none is acceptable.
+func negotiateEncoding(header string, encoders map[string]Encoder) string {
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if coding == "*" {
+			wildcard = q
+		} else {
+			accepted[coding] = q
+		}
+	}
+
+	names := make([]string, 0, len(encoders))
+	for k := range encoders {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return encodingRank(names[i]) < encodingRank(names[j]) ||
+			encodingRank(names[i]) == encodingRank(names[j]) && names[i] < names[j]
+	})
+
+	var best string
+	var bestQ float64
+
+	for _, name := range names {
+		q, ok := accepted[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// encodingRank returns the preference of an encoding, lowest first.
+func encodingRank(coding string) int {
+	switch coding {
+	case "br":
+		return 0
+	case "gzip":
+		return 1
+	}
+	return 2
+}
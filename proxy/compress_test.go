@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+// respondAccepting returns the response of h for a GET request accepting the
+// given encodings.
+func respondAccepting(t *testing.T, h apex.Handler, accept string) *Response {
+	event := `{"httpMethod":"GET","path":"/","headers":{"Accept-Encoding":"` + accept + `"}}`
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	return v.(*Response)
+}
+
+// reverse is an Encoder reversing the output, for testing.
+type reverse struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (r *reverse) Write(b []byte) (int, error) {
+	return r.buf.Write(b)
+}
+
+func (r *reverse) Close() error {
+	b := r.buf.Bytes()
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	_, err := r.w.Write(b)
+	return err
+}
+
+func TestNewHandler_compress(t *testing.T) {
+	body := `{"items":"` + strings.Repeat("a", 2000) + `"}`
+	h := NewHandler(write(body, "Content-Type", "application/json", "Vary", "Origin"), Options{Compress: true})
+
+	res := respondAccepting(t, h, "deflate, gzip;q=0.8")
+	assert.True(t, res.IsBase64Encoded)
+	assert.Equal(t, "gzip", res.Headers["Content-Encoding"])
+	assert.Equal(t, "Origin, Accept-Encoding", res.Headers["Vary"])
+	assert.Equal(t, []string{"Origin, Accept-Encoding"}, res.MultiValueHeaders["Vary"])
+
+	b, err := base64.StdEncoding.DecodeString(res.Body)
+	assert.NoError(t, err)
+	assert.True(t, len(b) < len(body))
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	assert.NoError(t, err)
+	b, _ = ioutil.ReadAll(r)
+	assert.Equal(t, body, string(b))
+
+	// Not accepted
+	res = respondAccepting(t, h, "gzip;q=0, identity")
+	assert.False(t, res.IsBase64Encoded)
+	assert.Equal(t, body, res.Body)
+	assert.NotContains(t, res.Headers, "Content-Encoding")
+	assert.Equal(t, "Origin, Accept-Encoding", res.Headers["Vary"])
+
+	// Below the threshold
+	h = NewHandler(write(`{}`, "Content-Type", "application/json"), Options{Compress: true})
+	res = respondAccepting(t, h, "gzip")
+	assert.Equal(t, `{}`, res.Body)
+	assert.NotContains(t, res.Headers, "Vary")
+
+	// Binary content
+	h = NewHandler(write(body, "Content-Type", "image/png"), Options{Compress: true})
+	res = respondAccepting(t, h, "gzip")
+	assert.NotContains(t, res.Headers, "Content-Encoding")
+
+	// Disabled
+	h = NewHandler(write(body, "Content-Type", "application/json"), Options{})
+	res = respondAccepting(t, h, "gzip")
+	assert.Equal(t, body, res.Body)
+}
+
+func TestNewHandler_compressEncoders(t *testing.T) {
+	h := NewHandler(write("hello", "Content-Type", "text/plain", "Content-Length", "5"), Options{
+		Compress:        true,
+		CompressMinSize: 1,
+		Encoders: map[string]Encoder{
+			"br": func(w io.Writer) io.WriteCloser { return &reverse{w: w} },
+		},
+	})
+
+	res := respondAccepting(t, h, "gzip, deflate, br")
+	assert.Equal(t, "br", res.Headers["Content-Encoding"])
+	assert.NotContains(t, res.Headers, "Content-Length")
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("olleh")), res.Body)
+
+	res = respondAccepting(t, h, "gzip, br;q=0.5")
+	assert.Equal(t, "gzip", res.Headers["Content-Encoding"])
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	encoders := map[string]Encoder{"gzip": nil, "br": nil, "zstd": nil}
+
+	cases := map[string]string{
+		"":                    "",
+		"identity":            "",
+		"gzip":                "gzip",
+		"GZIP":                "gzip",
+		"gzip, br":            "br",
+		"gzip;q=1.0, br;q=.5": "gzip",
+		"*":                   "br",
+		"*;q=0.5, br;q=0":     "gzip",
+		"zstd, compress":      "zstd",
+		"gzip;q=0":            "",
+	}
+
+	for in, out := range cases {
+		assert.Equal(t, out, negotiateEncoding(in, encoders), in)
+	}
+}
+
+func TestNewHandler_compressHeader(t *testing.T) {
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte(strings.Repeat("x", 2000)))
+	}), Options{Compress: true})
+
+	res := respondAccepting(t, h, "gzip")
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", 2000))), res.Body)
+}
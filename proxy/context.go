@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/apex/go-apex"
+)
+
+// contextKey is the type of request context keys.
+type contextKey int
+
+const (
+	eventKey contextKey = iota
+	lambdaContextKey
+	basePathKey
+)
+
+// newContext returns a copy of parent carrying the event and Lambda context.
+func newContext(parent context.Context, e *Event, ctx *apex.Context) context.Context {
+	parent = context.WithValue(parent, eventKey, e)
+	return context.WithValue(parent, lambdaContextKey, ctx)
+}
+
+// EventFromRequest returns the event r was built from, or nil when r was not
+// built by this package.
+func EventFromRequest(r *http.Request) *Event {
+	e, _ := r.Context().Value(eventKey).(*Event)
+	return e
+}
+
+// ContextFromRequest returns the Lambda context of the invocation r was built
+// from, or nil when r was not built by this package.
+func ContextFromRequest(r *http.Request) *apex.Context {
+	ctx, _ := r.Context().Value(lambdaContextKey).(*apex.Context)
+	return ctx
+}
+
+// RequestID returns the Lambda request ID of the invocation r was built from,
+// or an empty string.
+func RequestID(r *http.Request) string {
+	if ctx := ContextFromRequest(r); ctx != nil {
+		return ctx.RequestID
+	}
+	return ""
+}
+
+// PathParam returns the value of the path parameter name, for example "id"
+// for a resource "/users/{id}", or an empty string.
+func PathParam(r *http.Request, name string) string {
+	if e := EventFromRequest(r); e != nil {
+		return e.PathParameters[name]
+	}
+	return ""
+}
+
+// StageVariable returns the value of the stage variable name, or an empty
+// string.
+func StageVariable(r *http.Request, name string) string {
+	if e := EventFromRequest(r); e != nil {
+		return e.StageVariables[name]
+	}
+	return ""
+}
+
+// Claims returns the authorizer claims or context of r with their JSON types,
+// or nil.
+func Claims(r *http.Request) map[string]interface{} {
+	if e := EventFromRequest(r); e != nil && e.RequestContext != nil {
+		return e.RequestContext.Claims
+	}
+	return nil
+}
+
+// Authorizer returns the authorizer claims or context of r, or nil.
+func Authorizer(r *http.Request) map[string]string {
+	if e := EventFromRequest(r); e != nil && e.RequestContext != nil {
+		return e.RequestContext.Authorizer
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods are the methods allowed by CORS without AllowMethods.
+var DefaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORS configures cross-origin resource sharing. API Gateway does not add
+// CORS headers to the responses of proxy integrations, so the handler adds
+// them to every response, where the handler may override them, and answers
+// preflight requests itself.
+type CORS struct {
+	// AllowOrigins are the allowed origins, such as "https://example.com",
+	// "https://*.example.com" for its subdomains, or "*" for any origin
+	// without credentials.
+	AllowOrigins []string
+
+	// AllowMethods are the methods allowed in preflight requests, defaults
+	// to DefaultCORSMethods.
+	AllowMethods []string
+
+	// AllowHeaders are the request headers allowed in preflight requests,
+	// defaults to the headers requested.
+	AllowHeaders []string
+
+	// ExposeHeaders are the response headers readable by clients.
+	ExposeHeaders []string
+
+	// AllowCredentials allows requests with cookies or authorization. The
+	// origin is then echoed, and AllowOrigins may not contain "*", as any
+	// website could otherwise make credentialed requests.
+	AllowCredentials bool
+
+	// MaxAge is how long preflight responses may be cached.
+	MaxAge time.Duration
+
+	// Stages replaces the configuration for the given stages.
+	Stages map[string]*CORS
+}
+
+// validate returns an error when the configuration, or that of a stage,
+// allows any origin with credentials.
+func (c *CORS) validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.AllowCredentials {
+		for _, o := range c.AllowOrigins {
+			if o == "*" {
+				return errors.New(`AllowOrigins "*" is not allowed with AllowCredentials`)
+			}
+		}
+	}
+
+	for _, s := range c.Stages {
+		if err := s.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forStage returns the configuration of stage.
+func (c *CORS) forStage(stage string) *CORS {
+	if c == nil {
+		return nil
+	}
+
+	if s, ok := c.Stages[stage]; ok {
+		return s
+	}
+
+	return c
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or an
+// empty string when origin is not allowed.
+func (c *CORS) allowOrigin(origin string) string {
+	for _, o := range c.AllowOrigins {
+		if o == "*" && !c.AllowCredentials {
+			return "*"
+		}
+	}
+
+	if origin == "" {
+		return ""
+	}
+
+	for _, o := range c.AllowOrigins {
+		if o != "*" && matchOrigin(o, origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// varyOrigin reports whether the response depends on the request origin.
+func (c *CORS) varyOrigin() bool {
+	for _, o := range c.AllowOrigins {
+		if o == "*" && !c.AllowCredentials {
+			return false
+		}
+	}
+	return true
+}
+
+// matchOrigin reports whether origin matches pattern, which may be "*" or
+// contain a "*" matching subdomains. Origins are only echoed for patterns
+// other than "*".
+func matchOrigin(pattern, origin string) bool {
+	pattern = strings.ToLower(pattern)
+	origin = strings.ToLower(origin)
+
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return false
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+}
+
+// setHeaders sets the CORS headers of the response to a request from origin.
+func (c *CORS) setHeaders(h http.Header, origin string) {
+	if c.varyOrigin() {
+		addVary(h, "Origin")
+	}
+
+	origin = c.allowOrigin(origin)
+	if origin == "" {
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", origin)
+
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if len(c.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposeHeaders, ", "))
+	}
+}
+
+// isPreflight reports whether req is a CORS preflight request.
+func isPreflight(req *http.Request) bool {
+	return req.Method == "OPTIONS" &&
+		req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// preflight answers the preflight request req. The CORS headers are omitted
+// when the origin or method is not allowed, failing the request.
+func (c *CORS) preflight(w *ResponseWriter, req *http.Request) {
+	h := w.Header()
+	addVary(h, "Access-Control-Request-Method")
+	addVary(h, "Access-Control-Request-Headers")
+
+	methods := c.AllowMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+
+	if !containsFold(methods, req.Header.Get("Access-Control-Request-Method")) {
+		h.Del("Access-Control-Allow-Origin")
+		h.Del("Access-Control-Allow-Credentials")
+		h.Del("Access-Control-Expose-Headers")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if h.Get("Access-Control-Allow-Origin") != "" {
+		h.Del("Access-Control-Expose-Headers")
+		h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+		if len(c.AllowHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowHeaders, ", "))
+		} else if v := req.Header.Get("Access-Control-Request-Headers"); v != "" {
+			h.Set("Access-Control-Allow-Headers", v)
+		}
+
+		if c.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// containsFold reports whether values contains s, ignoring case.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
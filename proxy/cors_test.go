@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+// corsRequest returns the response of h for a request with the given method,
+// stage and headers.
+func corsRequest(t *testing.T, h apex.Handler, method, stage string, headers map[string]string) *Response {
+	e := &Event{
+		HTTPMethod:     method,
+		Path:           "/users",
+		Headers:        headers,
+		RequestContext: &RequestContext{Stage: stage},
+	}
+
+	b, err := json.Marshal(e)
+	assert.NoError(t, err)
+
+	v, err := h.Handle(json.RawMessage(b), &apex.Context{})
+	assert.NoError(t, err)
+	return v.(*Response)
+}
+
+func TestNewHandler_cors(t *testing.T) {
+	called := 0
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.Write([]byte("users"))
+	}), Options{
+		CORS: &CORS{
+			AllowOrigins:     []string{"https://example.com", "https://*.example.com"},
+			AllowCredentials: true,
+			ExposeHeaders:    []string{"X-Request-Id"},
+			MaxAge:           10 * time.Minute,
+			Stages: map[string]*CORS{
+				"dev": {AllowOrigins: []string{"*"}, AllowMethods: []string{"GET"}, AllowHeaders: []string{"Authorization"}},
+			},
+		},
+	})
+
+	preflight := map[string]string{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "Content-Type, Authorization",
+	}
+
+	res := corsRequest(t, h, "OPTIONS", "prod", preflight)
+	assert.Equal(t, 0, called)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Equal(t, "https://app.example.com", res.Headers["Access-Control-Allow-Origin"])
+	assert.Equal(t, "true", res.Headers["Access-Control-Allow-Credentials"])
+	assert.Equal(t, "GET, HEAD, POST, PUT, PATCH, DELETE", res.Headers["Access-Control-Allow-Methods"])
+	assert.Equal(t, "Content-Type, Authorization", res.Headers["Access-Control-Allow-Headers"])
+	assert.Equal(t, "600", res.Headers["Access-Control-Max-Age"])
+	assert.Equal(t, "Origin, Access-Control-Request-Method, Access-Control-Request-Headers", res.Headers["Vary"])
+	assert.NotContains(t, res.Headers, "Access-Control-Expose-Headers")
+
+	res = corsRequest(t, h, "GET", "prod", map[string]string{"Origin": "https://example.com"})
+	assert.Equal(t, 1, called)
+	assert.Equal(t, "users", res.Body)
+	assert.Equal(t, "https://example.com", res.Headers["Access-Control-Allow-Origin"])
+	assert.Equal(t, "true", res.Headers["Access-Control-Allow-Credentials"])
+	assert.Equal(t, "X-Request-Id", res.Headers["Access-Control-Expose-Headers"])
+	assert.Equal(t, "Origin", res.Headers["Vary"])
+
+	// Disallowed origin
+	res = corsRequest(t, h, "GET", "prod", map[string]string{"Origin": "https://evil.com"})
+	assert.Equal(t, "users", res.Body)
+	assert.NotContains(t, res.Headers, "Access-Control-Allow-Origin")
+	assert.Equal(t, "Origin", res.Headers["Vary"])
+
+	res = corsRequest(t, h, "OPTIONS", "prod", map[string]string{
+		"Origin":                        "https://example.com.evil.com",
+		"Access-Control-Request-Method": "GET",
+	})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.NotContains(t, res.Headers, "Access-Control-Allow-Origin")
+	assert.NotContains(t, res.Headers, "Access-Control-Allow-Methods")
+
+	// Stage configuration
+	res = corsRequest(t, h, "GET", "dev", map[string]string{"Origin": "http://localhost:3000"})
+	assert.Equal(t, "*", res.Headers["Access-Control-Allow-Origin"])
+	assert.NotContains(t, res.Headers, "Access-Control-Allow-Credentials")
+	assert.NotContains(t, res.Headers, "Vary")
+
+	res = corsRequest(t, h, "OPTIONS", "dev", preflight)
+	assert.NotContains(t, res.Headers, "Access-Control-Allow-Origin")
+	assert.NotContains(t, res.Headers, "Access-Control-Allow-Methods")
+
+	preflight["Access-Control-Request-Method"] = "GET"
+	res = corsRequest(t, h, "OPTIONS", "dev", preflight)
+	assert.Equal(t, "*", res.Headers["Access-Control-Allow-Origin"])
+	assert.Equal(t, "GET", res.Headers["Access-Control-Allow-Methods"])
+	assert.Equal(t, "Authorization", res.Headers["Access-Control-Allow-Headers"])
+
+	// OPTIONS requests which are not preflights reach the handler
+	corsRequest(t, h, "OPTIONS", "prod", map[string]string{"Origin": "https://example.com"})
+	assert.Equal(t, 4, called)
+}
+
+func TestNewHandler_corsCredentials(t *testing.T) {
+	assert.PanicsWithValue(t, `proxy: invalid CORS: AllowOrigins "*" is not allowed with AllowCredentials`, func() {
+		NewHandler(nil, Options{CORS: &CORS{AllowOrigins: []string{"*"}, AllowCredentials: true}})
+	})
+
+	assert.Panics(t, func() {
+		NewHandler(nil, Options{CORS: &CORS{
+			AllowOrigins: []string{"https://example.com"},
+			Stages: map[string]*CORS{
+				"dev": {AllowOrigins: []string{"https://example.com", "*"}, AllowCredentials: true},
+			},
+		}})
+	})
+
+	// The origin is never echoed for "*"
+	c := &CORS{AllowOrigins: []string{"*"}, AllowCredentials: true}
+	assert.Equal(t, "", c.allowOrigin("https://evil.com"))
+}
+
+func TestMatchOrigin(t *testing.T) {
+	assert.True(t, matchOrigin("*", "https://example.com"))
+	assert.True(t, matchOrigin("https://example.com", "https://EXAMPLE.com"))
+	assert.True(t, matchOrigin("https://*.example.com", "https://a.b.example.com"))
+	assert.False(t, matchOrigin("https://*.example.com", "https://.example.com"))
+	assert.False(t, matchOrigin("https://*.example.com", "https://example.com"))
+	assert.False(t, matchOrigin("https://*.example.com", "http://a.example.com"))
+	assert.False(t, matchOrigin("https://example.com", "https://example.com.evil.com"))
+}
@@ -19,56 +19,235 @@
 
 package proxy
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
 
 type requestContextAlias RequestContext
 
-type authorizer map[string]string
+type authorizer struct {
+	values      map[string]string
+	claims      map[string]interface{}
+	principalID string
+	latency     int
+	scopes      []string
+	iam         *IAM
+	raw         json.RawMessage
+	source      string
+}
 
 // UnmarshalJSON interprets the data as a dynamic map which may carry either a
-// Amazon Cognito set of claims or a custom set of attributes. It then choose
-// the good one at runtime and fill the authorizer with it.
+// Amazon Cognito set of claims, an HTTP API JWT or Lambda authorizer context,
+// an IAM identity, or a custom set of attributes. It then choose the good one
+// at runtime and fill the authorizer with it.
 func (a *authorizer) UnmarshalJSON(data []byte) error {
-	var cognito struct {
-		Claims *map[string]string
-	}
-	var custom map[string]string
+	var fields map[string]json.RawMessage
 
-	err := json.Unmarshal(data, &cognito)
-	if err != nil {
+	err := json.Unmarshal(data, &fields)
+	if err != nil || fields == nil {
 		return err
 	}
 
-	if cognito.Claims != nil {
-		*a = authorizer(*cognito.Claims)
+	a.raw = append(json.RawMessage(nil), data...)
+
+	// Lambda authorizers of REST APIs add these to their context
+	json.Unmarshal(fields["principalId"], &a.principalID)
+	json.Unmarshal(fields["integrationLatency"], &a.latency)
+
+	if claims, ok := object(fields, "claims"); ok {
+		a.source = "claims"
+		return a.setClaims(claims)
+	}
+
+	if jwt, ok := object(fields, "jwt"); ok {
+		a.source = "jwt"
+		var j map[string]json.RawMessage
+		if err := json.Unmarshal(jwt, &j); err != nil {
+			return err
+		}
+		json.Unmarshal(j["scopes"], &a.scopes)
+		if claims, ok := object(j, "claims"); ok {
+			return a.setClaims(claims)
+		}
 		return nil
 	}
 
-	err = json.Unmarshal(data, &custom)
-	if err != nil {
+	if lambda, ok := object(fields, "lambda"); ok {
+		a.source = "lambda"
+		return a.setClaims(lambda)
+	}
+
+	if iam, ok := object(fields, "iam"); ok {
+		a.source = "iam"
+		return json.Unmarshal(iam, &a.iam)
+	}
+
+	return a.setClaims(data)
+}
+
+// setClaims fills the claims from the JSON object data, and their string
+// view where values other than strings are kept JSON encoded.
+func (a *authorizer) setClaims(data json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &a.claims); err != nil {
 		return err
 	}
 
-	*a = authorizer(custom)
+	a.values = make(map[string]string, len(fields))
+	for k, v := range fields {
+		a.values[k] = claimString(v)
+	}
+
 	return nil
 }
 
+// claimString returns the string view of a claim, where values other than
+// strings are kept JSON encoded.
+func claimString(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		var buf bytes.Buffer
+		json.Compact(&buf, v)
+		s = buf.String()
+	}
+	return s
+}
+
+// MarshalJSON returns the authorizer document as received, with the changes
+// made to its decoded fields applied, or else the IAM identity, the claims or
+// the map of attributes.
+func (a authorizer) MarshalJSON() ([]byte, error) {
+	switch {
+	case a.raw != nil:
+		return a.marshalRaw()
+	case a.iam != nil:
+		return json.Marshal(map[string]*IAM{"iam": a.iam})
+	case a.claims != nil:
+		return json.Marshal(a.claims)
+	}
+
+	return json.Marshal(a.values)
+}
+
+// marshalRaw returns the received document, or when the decoded fields were
+// changed the document with the changes applied where the fields were found.
+func (a authorizer) marshalRaw() ([]byte, error) {
+	var orig authorizer
+	if err := json.Unmarshal(a.raw, &orig); err != nil {
+		return nil, err
+	}
+
+	if reflect.DeepEqual(a.values, orig.values) &&
+		reflect.DeepEqual(a.claims, orig.claims) &&
+		reflect.DeepEqual(a.iam, orig.iam) &&
+		reflect.DeepEqual(a.scopes, orig.scopes) &&
+		a.principalID == orig.principalID &&
+		a.latency == orig.latency {
+		return a.raw, nil
+	}
+
+	var doc map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(a.raw))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	claims := a.editedClaims(&orig)
+
+	switch orig.source {
+	case "claims", "lambda":
+		doc[orig.source] = claims
+	case "jwt":
+		jwt := doc["jwt"].(map[string]interface{})
+		if claims != nil {
+			jwt["claims"] = claims
+		}
+		if a.scopes != nil {
+			jwt["scopes"] = a.scopes
+		}
+	case "iam":
+		doc["iam"] = a.iam
+	default:
+		doc = make(map[string]interface{}, len(claims))
+		for k, v := range claims {
+			doc[k] = v
+		}
+	}
+
+	if a.principalID != orig.principalID {
+		doc["principalId"] = a.principalID
+	}
+
+	if a.latency != orig.latency {
+		doc["integrationLatency"] = a.latency
+	}
+
+	return json.Marshal(doc)
+}
+
+// editedClaims returns the claims with the changes made to their string view
+// applied, as either may be edited.
+func (a authorizer) editedClaims(orig *authorizer) map[string]interface{} {
+	if reflect.DeepEqual(a.values, orig.values) {
+		return a.claims
+	}
+
+	claims := make(map[string]interface{}, len(a.values))
+	for k, v := range a.claims {
+		b, err := json.Marshal(v)
+		if s, ok := a.values[k]; ok && err == nil && s == claimString(b) {
+			claims[k] = v
+		}
+	}
+
+	for k, s := range a.values {
+		if _, ok := claims[k]; !ok {
+			claims[k] = s
+		}
+	}
+
+	return claims
+}
+
+// object returns the field named key when it holds a JSON object.
+func object(fields map[string]json.RawMessage, key string) (json.RawMessage, bool) {
+	v, ok := fields[key]
+	if !ok {
+		return nil, false
+	}
+
+	v = bytes.TrimSpace(v)
+	return v, len(v) > 0 && v[0] == '{'
+}
+
 type jsonRequestContext struct {
 	*requestContextAlias
-	Authorizer authorizer
+	Authorizer authorizer `json:"authorizer"`
 }
 
 // UnmarshalJSON interprets data as a RequestContext with a special authorizer.
 // It then leverages type aliasing and struct embedding to fill RequestContext
-// with an usual map[string]string.
+// with an usual map[string]string and the typed claims.
 func (rc *RequestContext) UnmarshalJSON(data []byte) error {
-	var jrc jsonRequestContext
+	jrc := jsonRequestContext{requestContextAlias: (*requestContextAlias)(rc)}
 	if err := json.Unmarshal(data, &jrc); err != nil {
 		return err
 	}
 
-	*rc = *(*RequestContext)(jrc.requestContextAlias)
-	rc.Authorizer = jrc.Authorizer
+	rc.Authorizer = jrc.Authorizer.values
+	rc.Claims = jrc.Authorizer.claims
+	rc.PrincipalID = jrc.Authorizer.principalID
+	rc.IntegrationLatency = jrc.Authorizer.latency
+	rc.Scopes = jrc.Authorizer.scopes
+	rc.IAM = jrc.Authorizer.iam
+	rc.RawAuthorizer = jrc.Authorizer.raw
 
 	return nil
 }
@@ -78,6 +257,14 @@ func (rc *RequestContext) UnmarshalJSON(data []byte) error {
 func (rc *RequestContext) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&jsonRequestContext{
 		(*requestContextAlias)(rc),
-		rc.Authorizer,
+		authorizer{
+			values:      rc.Authorizer,
+			claims:      rc.Claims,
+			principalID: rc.PrincipalID,
+			latency:     rc.IntegrationLatency,
+			scopes:      rc.Scopes,
+			iam:         rc.IAM,
+			raw:         rc.RawAuthorizer,
+		},
 	})
 }
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/apex/go-apex"
+)
+
+// Error describes an invocation which could not be handled, because the
+// event is malformed or the handler panicked.
+type Error struct {
+	// Status is 400 for malformed events and 500 for panics.
+	Status int
+
+	// RequestID is the Lambda request ID of the invocation.
+	RequestID string
+
+	// Err is the cause.
+	Err error
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// problem is an RFC 7807 problem document.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// WriteProblem writes err as a JSON problem document. The cause is only
+// detailed for client errors.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err *Error) {
+	p := &problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(err.Status),
+		Status:    err.Status,
+		RequestID: err.RequestID,
+	}
+
+	if err.Status < 500 {
+		p.Detail = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// renderError returns the response for the failure cause of the invocation.
+// WriteProblem is used when Options.RenderError panics.
+func (p *handler) renderError(info *requestInfo, req *http.Request, ctx *apex.Context, status int, cause error) *ResponseWriter {
+	err := &Error{Status: status, Err: cause}
+	if ctx != nil {
+		err.RequestID = ctx.RequestID
+	}
+
+	w := p.newResponseWriter(info)
+
+	if p.options.RenderError != nil {
+		if p.callRenderError(w, req, err) {
+			return w
+		}
+		w = p.newResponseWriter(info)
+	}
+
+	WriteProblem(w, req, err)
+	return w
+}
+
+// callRenderError calls Options.RenderError, recovering from panics and
+// reporting whether it returned.
+func (p *handler) callRenderError(w http.ResponseWriter, req *http.Request, err *Error) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("panic rendering error: %v\n%s", v, debug.Stack())
+		}
+	}()
+
+	p.options.RenderError(w, req, err)
+	return true
+}
+
+// fail returns the response for the failure cause of the invocation, or the
+// cause as a Lambda error when Options.LambdaErrors is set. The request and
+// its info are nil when the request could not be built.
+func (p *handler) fail(e *Event, req *http.Request, info *requestInfo, ctx *apex.Context, status int, cause error) (interface{}, error) {
+	if p.options.LambdaErrors {
+		return nil, cause
+	}
+
+	if status < 500 {
+		log.Printf("error handling event: %s", cause)
+	}
+
+	w := p.renderError(info, req, ctx, status, cause)
+	w.finish()
+
+	return p.response(w, e), nil
+}
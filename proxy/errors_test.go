@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHandler_errors(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("boom")
+	}))
+
+	ctx := &apex.Context{RequestID: "abc"}
+
+	// Malformed event
+	v, err := h.Handle(json.RawMessage(`{"httpMethod": 1}`), ctx)
+	assert.NoError(t, err)
+	res := v.(*Response)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "application/problem+json", res.Headers["Content-Type"])
+	assert.Contains(t, res.Body, `"title":"Bad Request","status":400,"detail":"Parse proxy event: `)
+	assert.Contains(t, res.Body, `"requestId":"abc"`)
+
+	// Malformed body of a payload format 2.0 event
+	v, err = h.Handle(json.RawMessage(`{"version": "2.0", "rawPath": "/", "body": "%", "isBase64Encoded": true}`), ctx)
+	assert.NoError(t, err)
+	res2 := v.(*ResponseV2)
+	assert.Equal(t, http.StatusBadRequest, res2.StatusCode)
+	assert.Contains(t, res2.Body, `"detail":"Build request: Decode base64 request body: `)
+
+	// Panic
+	v, err = h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/"}`), ctx)
+	assert.NoError(t, err)
+	res = v.(*Response)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"requestId":"abc"}`+"\n", res.Body)
+	assert.Contains(t, buf.String(), "panic handling GET /: boom")
+
+	// Panic with a deadline
+	ctx.DeadlineMs = time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond)
+	v, err = h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/"}`), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, v.(*Response).StatusCode)
+}
+
+func TestNewHandler_renderError(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(os.Stderr)
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), Options{
+		RenderError: func(w http.ResponseWriter, r *http.Request, err *Error) {
+			w.WriteHeader(err.Status)
+			if r != nil {
+				w.Write([]byte(r.URL.Path + ": "))
+			}
+			w.Write([]byte(err.Error()))
+		},
+	})
+
+	v, err := h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/users"}`), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/users: panic: boom", v.(*Response).Body)
+
+	v, err = h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/", "body": "%", "isBase64Encoded": true}`), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, 400, v.(*Response).StatusCode)
+}
+
+func TestNewHandler_renderErrorPanic(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), Options{
+		RenderError: func(w http.ResponseWriter, r *http.Request, err *Error) {
+			w.Header().Set("X-Partial", "1")
+			w.WriteHeader(http.StatusTeapot)
+			panic("render")
+		},
+	})
+
+	v, err := h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/"}`), &apex.Context{RequestID: "abc"})
+	assert.NoError(t, err)
+
+	res := v.(*Response)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	assert.Equal(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"requestId":"abc"}`+"\n", res.Body)
+	assert.NotContains(t, res.Headers, "X-Partial")
+	assert.Contains(t, buf.String(), "panic rendering error: render")
+}
+
+func TestNewHandler_lambdaErrors(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(os.Stderr)
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), Options{LambdaErrors: true})
+
+	_, err := h.Handle(json.RawMessage(`{"httpMethod": 1}`), &apex.Context{})
+	assert.Contains(t, err.Error(), "Parse proxy event: ")
+
+	_, err = h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/"}`), &apex.Context{})
+	assert.EqualError(t, err, "panic: boom")
+}
+
+func TestNewHandler_streamPanic(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(os.Stderr)
+
+	var buf bytes.Buffer
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), Options{
+		Stream: func(*apex.Context) io.Writer { return &buf },
+	})
+
+	_, err := h.Handle(functionURLEvent, &apex.Context{RequestID: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"statusCode":500,"headers":{"Content-Type":"application/problem+json"}}`+"\x00\x00\x00\x00\x00\x00\x00\x00"+
+		`{"type":"about:blank","title":"Internal Server Error","status":500,"requestId":"abc"}`+"\n", buf.String())
+}
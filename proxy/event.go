@@ -19,83 +19,134 @@
 
 package proxy
 
-import "encoding/json"
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+)
 
 // Identity provides identity information about the API caller.
 type Identity struct {
 	// The API owner key associated with the API.
-	APIKey string
+	APIKey string `json:"apiKey"`
 
 	// The AWS account ID associated with the request.
-	AccountID string
+	AccountID string `json:"accountId"`
 
 	// The User Agent of the API caller.
-	UserAgent string
+	UserAgent string `json:"userAgent"`
 
 	// The source IP address of the TCP connection making the request to
 	// Amazon API Gateway.
-	SourceIP string
+	SourceIP string `json:"sourceIp"`
 
 	// The Amazon Access Key associated with the request.
-	AccessKey string
+	AccessKey string `json:"accessKey"`
 
 	// The principal identifier of the caller making the request.
 	// It is same as the User and interchangeable.
-	Caller string
+	Caller string `json:"caller"`
 
 	// The principal identifier of the user making the request.
 	// It is same as the Caller and interchangeable.
-	User string
+	User string `json:"user"`
 
 	// The Amazon Resource Name (ARN) of the effective user identified after
 	// authentication.
-	UserARN string
+	UserARN string `json:"userArn"`
 
 	// The Amazon Cognito identity ID of the caller making the request.
 	// Available only if the request was signed with Amazon Cognito credentials.
-	CognitoIdentityID string
+	CognitoIdentityID string `json:"cognitoIdentityId"`
 
 	// The Amazon Cognito identity pool ID of the caller making the request.
 	// Available only if the request was signed with Amazon Cognito credentials.
-	CognitoIdentityPoolID string
+	CognitoIdentityPoolID string `json:"cognitoIdentityPoolId"`
 
 	// The Amazon Cognito authentication type of the caller making the request.
 	// Available only if the request was signed with Amazon Cognito credentials.
-	CognitoAuthenticationType string
+	CognitoAuthenticationType string `json:"cognitoAuthenticationType"`
 
 	// The Amazon Cognito authentication provider used by the caller making the
 	// request.
 	// Available only if the request was signed with Amazon Cognito credentials.
-	CognitoAuthenticationProvider string
+	CognitoAuthenticationProvider string `json:"cognitoAuthenticationProvider"`
+
+	// The AWS Organizations ID of the caller's account.
+	PrincipalOrgID string `json:"principalOrgId,omitempty"`
+
+	// The client certificate of a request to a custom domain with mutual TLS
+	// authentication.
+	ClientCert *ClientCert `json:"clientCert,omitempty"`
+}
+
+// ClientCert provides the client certificate of a mutual TLS request.
+type ClientCert struct {
+	// The PEM encoded certificate.
+	ClientCertPEM string `json:"clientCertPem"`
+
+	// The distinguished name of the subject.
+	SubjectDN string `json:"subjectDN"`
+
+	// The distinguished name of the issuer.
+	IssuerDN string `json:"issuerDN"`
+
+	// The serial number of the certificate.
+	SerialNumber string `json:"serialNumber"`
+
+	// The validity period of the certificate.
+	Validity struct {
+		NotBefore string `json:"notBefore"`
+		NotAfter  string `json:"notAfter"`
+	} `json:"validity"`
+}
+
+// Certificate parses the PEM encoded certificate.
+func (c *ClientCert) Certificate() (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(c.ClientCertPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM encoded certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Authentication provides the authentication information of an HTTP API
+// payload format 2.0 event.
+type Authentication struct {
+	// The client certificate of a request to a custom domain with mutual TLS
+	// authentication.
+	ClientCert *ClientCert `json:"clientCert,omitempty"`
 }
 
 // RequestContext provides contextual information about an Amazon API Gateway
 // Proxy event.
 type RequestContext struct {
 	// The identifier Amazon API Gateway assigns to the API.
-	APIID string
+	APIID string `json:"apiId"`
 
 	// The identifier Amazon API Gateway assigns to the resource.
-	ResourceID string
+	ResourceID string `json:"resourceId"`
 
 	// An automatically generated ID for the API call.
-	RequestID string
+	RequestID string `json:"requestId"`
 
 	// The incoming request HTTP method name.
 	// Valid values include: DELETE, GET, HEAD, OPTIONS, PATCH, POST, and PUT.
-	HTTPMethod string
+	HTTPMethod string `json:"httpMethod"`
 
 	// The resource path as defined in Amazon API Gateway.
-	ResourcePath string
+	ResourcePath string `json:"resourcePath"`
 
 	// The AWS account ID associated with the API.
-	AccountID string
+	AccountID string `json:"accountId"`
 
 	// The deployment stage of the API call (for example, Beta or Prod).
-	Stage string
+	Stage string `json:"stage"`
 
 	// The API caller identification information.
-	Identity *Identity
+	Identity *Identity `json:"identity"`
 
 	// If used with Amazon Cognito, it represents the claims returned from the
 	// Amazon Cognito user pool after the method caller is successfully
@@ -103,48 +154,226 @@ type RequestContext struct {
 	// If used with Amazon API Gateway custom authorizer, it represents the
 	// specified key-value pair of the context map returned from the custom
 	// authorizer AWS Lambda function.
+	// If used with an HTTP API JWT authorizer, it represents the claims of
+	// the token.
+	// Values which are not strings, such as numbers or arrays, are JSON
+	// encoded, see Claims.
 	Authorizer map[string]string `json:"-"`
+
+	// The values of Authorizer with their JSON types, for example the array
+	// of a "cognito:groups" claim or a boolean returned by a Lambda authorizer.
+	Claims map[string]interface{} `json:"-"`
+
+	// The principal identifier returned by a REST API Lambda authorizer.
+	PrincipalID string `json:"-"`
+
+	// The latency in milliseconds of a REST API Lambda authorizer.
+	IntegrationLatency int `json:"-"`
+
+	// The scopes of the token of an HTTP API JWT authorizer.
+	Scopes []string `json:"-"`
+
+	// The authorizer document as received.
+	RawAuthorizer json.RawMessage `json:"-"`
+
+	// The identity of a caller authenticated with AWS IAM by an HTTP API or
+	// a Lambda function URL.
+	IAM *IAM `json:"-"`
+
+	// The selected route key of an HTTP API, for example "GET /users/{id}".
+	RouteKey string `json:"routeKey,omitempty"`
+
+	// The full domain name used to invoke the API or function URL.
+	DomainName string `json:"domainName,omitempty"`
+
+	// The first label of DomainName.
+	DomainPrefix string `json:"domainPrefix,omitempty"`
+
+	// The HTTP request description of an HTTP API payload format 2.0 event.
+	HTTP *HTTP `json:"http,omitempty"`
+
+	// The formatted request time of an HTTP API.
+	Time string `json:"time,omitempty"`
+
+	// The request time in milliseconds since the epoch of an HTTP API.
+	TimeEpoch int64 `json:"timeEpoch,omitempty"`
+
+	// The load balancer information of an Application Load Balancer event.
+	ELB *ELB `json:"elb,omitempty"`
+
+	// The request protocol of a REST API event, for example HTTP/1.1.
+	Protocol string `json:"protocol,omitempty"`
+
+	// The request path of a REST API event, including the base path of a
+	// custom domain or the stage.
+	Path string `json:"path,omitempty"`
+
+	// The ID API Gateway assigns to the request in its access logs.
+	ExtendedRequestID string `json:"extendedRequestId,omitempty"`
+
+	// The formatted request time of a REST API event.
+	RequestTime string `json:"requestTime,omitempty"`
+
+	// The request time in milliseconds since the epoch of a REST API event.
+	RequestTimeEpoch int64 `json:"requestTimeEpoch,omitempty"`
+
+	// The authentication information of an HTTP API payload format 2.0
+	// event.
+	Authentication *Authentication `json:"authentication,omitempty"`
 }
 
-// Event represents an Amazon API Gateway Proxy Event.
+// ClientCert returns the client certificate of a mutual TLS request, or nil.
+func (rc *RequestContext) ClientCert() *ClientCert {
+	if rc.Authentication != nil && rc.Authentication.ClientCert != nil {
+		return rc.Authentication.ClientCert
+	}
+
+	if rc.Identity != nil {
+		return rc.Identity.ClientCert
+	}
+
+	return nil
+}
+
+// IAM provides the identity of a caller authenticated with AWS IAM.
+type IAM struct {
+	// The AWS access key of the caller.
+	AccessKey string `json:"accessKey"`
+
+	// The AWS account ID of the caller.
+	AccountID string `json:"accountId"`
+
+	// The principal identifier of the caller.
+	CallerID string `json:"callerId"`
+
+	// The AWS Organizations ID of the caller's account.
+	PrincipalOrgID string `json:"principalOrgId"`
+
+	// The Amazon Resource Name (ARN) of the caller.
+	UserARN string `json:"userArn"`
+
+	// The user identifier of the caller.
+	UserID string `json:"userId"`
+
+	// The Amazon Cognito identity of the caller, when the request was signed
+	// with Amazon Cognito credentials.
+	CognitoIdentity *CognitoIdentity `json:"cognitoIdentity"`
+}
+
+// CognitoIdentity provides the Amazon Cognito identity of an IAM caller.
+type CognitoIdentity struct {
+	// The authentication methods references.
+	AMR []string `json:"amr"`
+
+	// The Amazon Cognito identity ID.
+	IdentityID string `json:"identityId"`
+
+	// The Amazon Cognito identity pool ID.
+	IdentityPoolID string `json:"identityPoolId"`
+}
+
+// ELB provides information about the Application Load Balancer target group
+// which invoked the function.
+type ELB struct {
+	// The Amazon Resource Name (ARN) of the target group.
+	TargetGroupARN string `json:"targetGroupArn"`
+}
+
+// HTTP describes the incoming request of an HTTP API payload format 2.0 event.
+type HTTP struct {
+	// The incoming request HTTP method name.
+	Method string `json:"method"`
+
+	// The request path.
+	Path string `json:"path"`
+
+	// The request protocol, for example HTTP/1.1.
+	Protocol string `json:"protocol"`
+
+	// The source IP address of the TCP connection making the request.
+	SourceIP string `json:"sourceIp"`
+
+	// The User Agent of the API caller.
+	UserAgent string `json:"userAgent"`
+}
+
+// Event represents an Amazon API Gateway Proxy Event. REST API events, HTTP
+// API events using payload format 1.0 or 2.0, Lambda function URL events and
+// Application Load Balancer target events are supported.
 type Event struct {
+	// The payload format version, "2.0" for HTTP API payload format 2.0 and
+	// "1.0" or empty otherwise.
+	Version string `json:"version,omitempty"`
+
+	// The selected route key of an HTTP API, for example "GET /users/{id}".
+	RouteKey string `json:"routeKey,omitempty"`
+
 	// The incoming request HTTP method name.
 	// Valid values include: DELETE, GET, HEAD, OPTIONS, PATCH, POST, and PUT.
-	HTTPMethod string
+	// Empty for payload format 2.0, see RequestContext.HTTP.
+	HTTPMethod string `json:"httpMethod"`
 
 	// The incoming reauest HTTP headers.
-	// Duplicate entries are not supported.
-	Headers map[string]string
+	// Only the last value of duplicate entries is kept, see MultiValueHeaders.
+	Headers map[string]string `json:"headers"`
+
+	// The incoming request HTTP headers including duplicate entries.
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
 
 	// The resource path with raw placeholders as defined in Amazon API Gateway.
-	Resource string
+	Resource string `json:"resource"`
 
 	// The incoming request path parameters corresponding to the resource path
 	// placeholders values as defined in Resource.
-	PathParameters map[string]string
+	PathParameters map[string]string `json:"pathParameters"`
 
 	// The real path corresponding to the path parameters injected into the
 	// Resource placeholders.
-	Path string
+	Path string `json:"path"`
+
+	// The raw request path of payload format 2.0.
+	RawPath string `json:"rawPath,omitempty"`
+
+	// The raw query string of payload format 2.0, without the leading "?".
+	RawQueryString string `json:"rawQueryString,omitempty"`
+
+	// The incoming request cookies of payload format 2.0, which are not
+	// present in Headers.
+	Cookies []string `json:"cookies,omitempty"`
 
 	// The incoming request query string parameters.
-	// Duplicate entries are not supported.
-	QueryStringParameters map[string]string
+	// Only the last value of duplicate entries is kept, see
+	// MultiValueQueryStringParameters.
+	QueryStringParameters map[string]string `json:"queryStringParameters"`
+
+	// The incoming request query string parameters including duplicate
+	// entries.
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
 
 	// If used with Amazon API Gateway binary support, it represents the Base64
 	// encoded binary data from the client.
 	// Otherwise it represents the raw data from the client.
-	Body string
+	Body string `json:"body"`
 
 	// A flag to indicate if the applicable request payload is Base64 encoded.
-	IsBase64Encoded bool
+	IsBase64Encoded bool `json:"isBase64Encoded"`
 
 	// The name-value pairs defined as configuration attributes associated with
 	// the deployment stage of the API.
-	StageVariables map[string]string
+	StageVariables map[string]string `json:"stageVariables"`
 
 	// The contextual information associated with the API call.
-	RequestContext *RequestContext
+	RequestContext *RequestContext `json:"requestContext"`
+}
+
+// isV2 reports whether the event uses payload format 2.0.
+func (e *Event) isV2() bool {
+	return e.Version == "2.0"
+}
+
+// isALB reports whether the event comes from an Application Load Balancer.
+func (e *Event) isALB() bool {
+	return e.RequestContext != nil && e.RequestContext.ELB != nil
 }
 
 // String returns the string representation.
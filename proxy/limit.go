@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// DefaultMaxResponseSize is the default Options.MaxResponseSize, the payload
+// limit of synchronous Lambda invocations.
+const DefaultMaxResponseSize = 6 * 1024 * 1024
+
+// Offloader stores the body of a response too large to be returned through
+// Lambda elsewhere, such as S3, and returns the URL the client is redirected
+// to. The header holds the response headers, including Content-Type and
+// Content-Encoding.
+type Offloader func(r *http.Request, header http.Header, body []byte) (string, error)
+
+// maxSize returns an upper bound of the JSON encoded size of the response in
+// any of the formats, as JSON escaping grows each byte to at most six bytes.
+func (w *ResponseWriter) maxSize() int {
+	n := 256 + 6*len(w.response.Body)
+
+	// The headers may be encoded both as single and multiple values
+	for k, vs := range w.response.MultiValueHeaders {
+		n += 2 * (6*len(k) + 8)
+		for _, v := range vs {
+			n += 2 * (6*len(v) + 4)
+		}
+	}
+
+	return n
+}
+
+// limit returns the finished response res, or when it exceeds the maximum
+// size a redirect to its offloaded body or an error response. It is only
+// called once the handler returned, so req may be passed to Offload.
+func (p *handler) limit(res *ResponseWriter, req *http.Request, info *requestInfo, e *Event) *ResponseWriter {
+	limit := p.options.MaxResponseSize
+	if limit == 0 {
+		limit = DefaultMaxResponseSize
+	}
+
+	if res.maxSize() <= limit {
+		return res
+	}
+
+	// The payload is measured as returned to Lambda, since JSON escaping
+	// grows bodies with quotes or HTML, such as "<" to "\u003c"
+	b, err := json.Marshal(p.response(res, e))
+	if err != nil {
+		log.Printf("error encoding response to %s: %s", route(e, info), err)
+		return res
+	}
+
+	size := len(b)
+	if size <= limit {
+		return res
+	}
+
+	log.Printf("response to %s too large: %d bytes, limit %d bytes", route(e, info), size, limit)
+
+	if p.options.Offload != nil {
+		header := make(http.Header, len(res.response.MultiValueHeaders))
+		for k, vs := range res.response.MultiValueHeaders {
+			header[k] = vs
+		}
+
+		url, err := p.options.Offload(req, header, res.output.Bytes())
+		if err == nil {
+			redirect := p.newResponseWriter(info)
+			http.Redirect(redirect, req, url, http.StatusSeeOther)
+			redirect.finish()
+			return redirect
+		}
+
+		log.Printf("error offloading response to %s: %s", route(e, info), err)
+	}
+
+	status := p.options.OversizeStatus
+	if status == 0 {
+		status = http.StatusBadGateway
+	}
+
+	tooLarge := p.newResponseWriter(info)
+	tooLarge.Header().Set("Content-Type", "application/json")
+	tooLarge.WriteHeader(status)
+	tooLarge.Write([]byte(`{"message":"Response too large"}`))
+	tooLarge.finish()
+	return tooLarge
+}
+
+// route returns the route of the request for logging, such as
+// "GET /users/{id}".
+func route(e *Event, info *requestInfo) string {
+	switch {
+	case e.RouteKey != "" && e.RouteKey != "$default":
+		return e.RouteKey
+	case e.Resource != "":
+		return info.method + " " + e.Resource
+	}
+
+	return info.method + " " + info.path
+}
@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHandler_maxResponseSize(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	body := strings.Repeat("a", 800)
+	event := json.RawMessage(`{"httpMethod":"GET","resource":"/reports/{id}","path":"/reports/1"}`)
+
+	h := NewHandler(write(body, "Content-Type", "text/plain"), Options{MaxResponseSize: 1000})
+	v, err := h.Handle(event, &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, body, v.(*Response).Body)
+	assert.Empty(t, buf.String())
+
+	// Base64 encoding grows the binary body beyond the limit
+	h = NewHandler(write(body, "Content-Type", "application/pdf"), Options{MaxResponseSize: 1000})
+	v, err = h.Handle(event, &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*Response)
+	assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+	assert.Equal(t, `{"message":"Response too large"}`, res.Body)
+	assert.Equal(t, "application/json", res.Headers["Content-Type"])
+	assert.Contains(t, buf.String(), "response to GET /reports/{id} too large: ")
+	assert.Contains(t, buf.String(), "limit 1000 bytes")
+
+	h = NewHandler(write(body, "Content-Type", "application/pdf"), Options{
+		MaxResponseSize: 1000,
+		OversizeStatus:  http.StatusRequestEntityTooLarge,
+	})
+	v, err = h.Handle(event, &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, v.(*Response).StatusCode)
+
+	// JSON escaping grows HTML and quoted bodies beyond the limit
+	for _, c := range []struct{ body, contentType string }{
+		{strings.Repeat("<", 1000), "text/html"},
+		{strings.Repeat(`"`, 1000), "application/json"},
+	} {
+		h = NewHandler(write(c.body, "Content-Type", c.contentType), Options{MaxResponseSize: 2000})
+		v, err = h.Handle(event, &apex.Context{})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, v.(*Response).StatusCode, c.contentType)
+	}
+}
+
+func TestNewHandler_offload(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	defer log.SetOutput(os.Stderr)
+
+	body := strings.Repeat("a", 2000)
+	event := json.RawMessage(`{"httpMethod":"GET","path":"/reports/1"}`)
+
+	var offloaded []byte
+	h := NewHandler(write(body, "Content-Type", "text/csv"), Options{
+		MaxResponseSize: 1000,
+		Offload: func(r *http.Request, header http.Header, b []byte) (string, error) {
+			assert.Equal(t, "/reports/1", r.URL.Path)
+			assert.Equal(t, "text/csv", header.Get("Content-Type"))
+			offloaded = b
+			return "https://bucket.s3.amazonaws.com/reports/1.csv", nil
+		},
+	})
+
+	v, err := h.Handle(event, &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*Response)
+	assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/reports/1.csv", res.Headers["Location"])
+	assert.Equal(t, body, string(offloaded))
+
+	h = NewHandler(write(body, "Content-Type", "text/csv"), Options{
+		MaxResponseSize: 1000,
+		Offload: func(r *http.Request, header http.Header, b []byte) (string, error) {
+			return "", errors.New("boom")
+		},
+	})
+
+	v, err = h.Handle(event, &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, v.(*Response).StatusCode)
+}
+
+func TestResponseWriter_maxSize(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: `"<>"`})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(strings.Repeat(`<"\&`, 100) + "\xff"))
+	})
+
+	for _, event := range []string{
+		`{"httpMethod":"GET","path":"/"}`,
+		`{"version":"2.0","rawPath":"/","requestContext":{"http":{"method":"GET"}}}`,
+		`{"httpMethod":"GET","path":"/","requestContext":{"elb":{}}}`,
+		`{"httpMethod":"GET","path":"/","multiValueHeaders":{},"requestContext":{"elb":{}}}`,
+	} {
+		e := &Event{}
+		assert.NoError(t, json.Unmarshal([]byte(event), e))
+
+		req, err := buildRequest(e, &apex.Context{})
+		assert.NoError(t, err)
+
+		p := NewHandler(h, Options{}).(*handler)
+		res := p.newResponseWriter(p.requestInfo(req))
+		h.ServeHTTP(res, req)
+		res.finish()
+
+		b, err := json.Marshal(p.response(res, e))
+		assert.NoError(t, err)
+		assert.True(t, len(b) <= res.maxSize(), event)
+	}
+}
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/apex/go-apex"
+)
+
+// ListenAndServeLocal listens on the TCP network address addr and converts
+// each request to a proxy event for h, matching the request path against the
+// API Gateway resource templates in routes. See LocalServer.
+func ListenAndServeLocal(addr string, h apex.Handler, routes []string) error {
+	return http.ListenAndServe(addr, &LocalServer{
+		Handler: h,
+		Routes:  routes,
+	})
+}
+
+// LocalServer is an http.Handler which emulates an API Gateway REST API Lambda
+// proxy integration, allowing the code path used in Lambda to be run locally.
+type LocalServer struct {
+	// Handler is invoked with the proxy events.
+	Handler apex.Handler
+
+	// Routes are API Gateway resource templates such as "/users/{id}" or
+	// "/files/{proxy+}". Requests matching no route are answered with 404.
+	// When empty every request is matched by "/" or "/{proxy+}".
+	Routes []string
+
+	// Stage is the deployment stage, defaults to "local".
+	Stage string
+
+	// StageVariables of the deployment stage.
+	StageVariables map[string]string
+
+	// BinaryMediaTypes are the request content types which are Base64
+	// encoded, such as "image/png", "image/*" or "*/*".
+	BinaryMediaTypes []string
+}
+
+// ServeHTTP implements http.Handler.
+func (s *LocalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routes := s.Routes
+	if len(routes) == 0 {
+		routes = []string{"/", "/{proxy+}"}
+	}
+
+	resource, params, ok := matchRoute(routes, r.URL.Path)
+	if !ok {
+		writeLocalError(w, http.StatusNotFound, "Missing Authentication Token")
+		return
+	}
+
+	event, err := s.event(r, resource, params)
+	if err != nil {
+		writeLocalError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		writeLocalError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx := &apex.Context{
+		RequestID:    event.RequestContext.RequestID,
+		FunctionName: "local",
+	}
+
+	v, err := s.Handler.Handle(json.RawMessage(b), ctx)
+	if err != nil {
+		log.Printf("error handling %s %s: %s", r.Method, r.URL.Path, err)
+		writeLocalError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	res, err := decodeResponse(v)
+	if err != nil {
+		log.Printf("error handling %s %s: %s", r.Method, r.URL.Path, err)
+		writeLocalError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+	defer res.Body.Close()
+
+	for k, vs := range res.Header {
+		w.Header()[k] = vs
+	}
+
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
+
+// event returns the proxy event API Gateway would send for r.
+func (s *LocalServer) event(r *http.Request, resource string, params map[string]string) (*Event, error) {
+	stage := s.Stage
+	if stage == "" {
+		stage = "local"
+	}
+
+	e, err := NewEventFromRequest(r, EventOptions{
+		Resource:         resource,
+		PathParameters:   params,
+		Stage:            stage,
+		StageVariables:   s.StageVariables,
+		BinaryMediaTypes: s.BinaryMediaTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.RequestContext.APIID = "local"
+	e.RequestContext.ResourceID = "local"
+
+	return e, nil
+}
+
+// matchRoute returns the most specific resource template matching path, and
+// the values of its path parameters. Literal segments take precedence over
+// parameters, which take precedence over greedy parameters.
+func matchRoute(routes []string, path string) (string, map[string]string, bool) {
+	var best string
+	var bestParams map[string]string
+	var bestScore []int
+
+	for _, route := range routes {
+		params, score, ok := matchTemplate(route, path)
+		if ok && (bestScore == nil || compareScores(score, bestScore) > 0) {
+			best, bestParams, bestScore = route, params, score
+		}
+	}
+
+	return best, bestParams, bestScore != nil
+}
+
+// matchTemplate matches path against the resource template, returning the
+// path parameters and a score per segment.
+func matchTemplate(template, path string) (map[string]string, []int, bool) {
+	tsegs := strings.Split(strings.Trim(template, "/"), "/")
+	psegs := strings.Split(strings.Trim(path, "/"), "/")
+	params := make(map[string]string)
+	score := []int{}
+
+	for i, t := range tsegs {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "+}") {
+			if i >= len(psegs) || psegs[i] == "" {
+				return nil, nil, false
+			}
+			rest := strings.Join(psegs[i:], "/")
+			params[t[1:len(t)-2]] = rest
+			return params, append(score, 1), true
+		}
+
+		if i >= len(psegs) {
+			return nil, nil, false
+		}
+
+		switch {
+		case strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}"):
+			if psegs[i] == "" {
+				return nil, nil, false
+			}
+			params[t[1:len(t)-1]] = psegs[i]
+			score = append(score, 2)
+		case t == psegs[i]:
+			score = append(score, 3)
+		default:
+			return nil, nil, false
+		}
+	}
+
+	if len(psegs) != len(tsegs) {
+		return nil, nil, false
+	}
+
+	return params, score, true
+}
+
+// compareScores compares segment scores lexicographically.
+func compareScores(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
+// remoteIP returns the host of addr.
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// newRequestID returns a random request identifier.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	s := hex.EncodeToString(b)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// writeLocalError writes an API Gateway style error response.
+func writeLocalError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
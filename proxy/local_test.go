@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalServer(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		e := EventFromRequest(r)
+		assert.Equal(t, "/users/{id}", e.Resource)
+		assert.Equal(t, "dev", e.RequestContext.Stage)
+		assert.Equal(t, "127.0.0.1", e.RequestContext.Identity.SourceIP)
+		assert.Equal(t, []string{"1", "2"}, r.URL.Query()["page"])
+
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		w.Write([]byte("user " + PathParam(r, "id")))
+	})
+
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		e := EventFromRequest(r)
+		assert.Equal(t, "/files/{proxy+}", e.Resource)
+		assert.Equal(t, "a/b.png", PathParam(r, "proxy"))
+		assert.True(t, e.IsBase64Encoded)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(body)
+	})
+
+	s := httptest.NewServer(&LocalServer{
+		Handler:          Serve(mux),
+		Routes:           []string{"/users/{id}", "/users/me", "/files/{proxy+}"},
+		Stage:            "dev",
+		BinaryMediaTypes: []string{"image/*"},
+	})
+	defer s.Close()
+
+	res, err := http.Get(s.URL + "/users/42?page=1&page=2")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "user 42", string(body))
+	assert.Len(t, res.Cookies(), 2)
+
+	png := []byte{0x89, 'P', 'N', 'G', 0}
+	res, err = http.Post(s.URL+"/files/a/b.png", "image/png", bytes.NewReader(png))
+	assert.NoError(t, err)
+	body, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, png, body)
+	assert.Equal(t, "image/png", res.Header.Get("Content-Type"))
+
+	res, err = http.Get(s.URL + "/missing")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestMatchRoute(t *testing.T) {
+	routes := []string{"/", "/users", "/users/{id}", "/users/me", "/users/{id}/posts/{post}", "/{proxy+}"}
+
+	cases := []struct {
+		path     string
+		resource string
+		params   map[string]string
+	}{
+		{"/", "/", map[string]string{}},
+		{"/users", "/users", map[string]string{}},
+		{"/users/", "/users", map[string]string{}},
+		{"/users/42", "/users/{id}", map[string]string{"id": "42"}},
+		{"/users/me", "/users/me", map[string]string{}},
+		{"/users/42/posts/7", "/users/{id}/posts/{post}", map[string]string{"id": "42", "post": "7"}},
+		{"/users/42/likes", "/{proxy+}", map[string]string{"proxy": "users/42/likes"}},
+	}
+
+	for _, c := range cases {
+		resource, params, ok := matchRoute(routes, c.path)
+		assert.True(t, ok, c.path)
+		assert.Equal(t, c.resource, resource, c.path)
+		assert.Equal(t, c.params, params, c.path)
+	}
+
+	_, _, ok := matchRoute([]string{"/{proxy+}"}, "/")
+	assert.False(t, ok)
+}
+
+func TestLocalServer_defaultRoutes(t *testing.T) {
+	s := httptest.NewServer(&LocalServer{
+		Handler: Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(EventFromRequest(r).Resource))
+		})),
+	})
+	defer s.Close()
+
+	for path, resource := range map[string]string{"/": "/", "/users/42": "/{proxy+}"} {
+		res, err := http.Get(s.URL + path)
+		assert.NoError(t, err)
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode, path)
+		assert.Equal(t, resource, string(body), path)
+	}
+}
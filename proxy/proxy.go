@@ -1,45 +1,324 @@
 package proxy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
 
 	"github.com/apex/go-apex"
 )
 
-// Serve adaptes an http.Handler to the apex.Handler interface.
+// Serve adaptes an http.Handler to the apex.Handler interface. The response
+// format is chosen from the event, so the same handler may be exposed through
+// API Gateway REST or HTTP APIs and Application Load Balancers.
 func Serve(h http.Handler) apex.Handler {
+	return NewHandler(h, Options{})
+}
+
+// Options configures a handler created with NewHandler.
+type Options struct {
+	// TextContentTypes are regular expressions matching the response content
+	// types which are not Base64 encoded. Defaults to the types configured
+	// with SetTextContentTypes, initially DefaultTextContentTypes.
+	TextContentTypes []string
+
+	// BinaryMediaTypes are media types, such as "image/png" or "image/*",
+	// of responses which are always Base64 encoded, even when they match
+	// TextContentTypes.
+	BinaryMediaTypes []string
+
+	// StripBasePath is removed from the beginning of request paths, such as
+	// the base path of a custom domain mapping.
+	StripBasePath string
+
+	// StripStage removes the stage from the beginning of request paths, for
+	// APIs whose event paths include it.
+	StripStage bool
+
+	// TimeoutMargin is subtracted from the invocation deadline to leave time
+	// for returning the timeout response. Defaults to DefaultTimeoutMargin.
+	TimeoutMargin time.Duration
+
+	// Timeout is the configured timeout of the function, counted from the
+	// start of the invocation. It sets the deadline when the shim provides
+	// none.
+	Timeout time.Duration
+
+	// Stream returns the writer used to stream the response of an invocation
+	// through a Lambda function URL or HTTP API, or nil to buffer it. The
+	// status and headers are written as a JSON prelude followed by eight NUL
+	// bytes, then the body as the handler writes it. Flushing the response
+	// flushes the writer when it implements http.Flusher. Handle returns a
+	// nil value for streamed responses.
+	Stream func(*apex.Context) io.Writer
+
+	// Compress enables compression of buffered text responses of at least
+	// CompressMinSize bytes, using gzip or one of Encoders as accepted by the
+	// Accept-Encoding header of the request. Compressed responses are Base64
+	// encoded.
+	Compress bool
+
+	// CompressMinSize defaults to DefaultCompressMinSize.
+	CompressMinSize int
+
+	// Encoders are additional encoders by content coding, such as "br".
+	Encoders map[string]Encoder
+
+	// MaxResponseSize is the size of the largest buffered response as encoded
+	// in the Lambda payload, including its headers and the growth of Base64
+	// encoding and JSON escaping. Defaults to DefaultMaxResponseSize. Larger
+	// responses are logged and replaced with a redirect to their body stored
+	// by Offload, or an error response.
+	MaxResponseSize int
+
+	// OversizeStatus is the status code of the error response replacing
+	// responses which are too large, such as 413. Defaults to 502.
+	OversizeStatus int
+
+	// Offload optionally stores the body of responses which are too large.
+	Offload Offloader
+
+	// CORS enables cross-origin resource sharing.
+	CORS *CORS
+
+	// RenderError writes the response for a malformed event, with status
+	// 400, or a panic of the handler, with status 500. Defaults to a JSON
+	// problem document, see RFC 7807, which is also used when RenderError
+	// panics. The request is nil when it could not be built from the event.
+	RenderError func(w http.ResponseWriter, r *http.Request, err *Error)
+
+	// LambdaErrors returns malformed events and handler panics as Lambda
+	// errors instead of HTTP responses, which API Gateway answers with 502.
+	LambdaErrors bool
+}
+
+// DefaultTimeoutMargin is the default Options.TimeoutMargin.
+const DefaultTimeoutMargin = 100 * time.Millisecond
+
+// options are the compiled Options.
+type options struct {
+	Options
+	text *regexp.Regexp
+}
+
+// NewHandler adapts an http.Handler to the apex.Handler interface like Serve,
+// using the given options. It panics if a text content type is not a valid
+// regular expression, or if CORS allows any origin with credentials.
+func NewHandler(h http.Handler, opts Options) apex.Handler {
 	if h == nil {
 		h = http.DefaultServeMux
 	}
 
-	return &handler{h}
+	text, err := compileTextContentTypes(opts.TextContentTypes)
+	if err != nil {
+		panic(fmt.Sprintf("proxy: invalid text content types: %s", err))
+	}
+
+	if err := opts.CORS.validate(); err != nil {
+		panic(fmt.Sprintf("proxy: invalid CORS: %s", err))
+	}
+
+	return &handler{
+		Handler: h,
+		options: &options{opts, text},
+	}
 }
 
 // Handler implements the apex.Handler interface and adapts it to an
 // http.Handler by converting the incoming event to an http.Request object
 type handler struct {
 	Handler http.Handler
+	options *options
 }
 
 // Handle accepts a request from the apex shim and dispatches it to an http.Handler
 func (p *handler) Handle(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	start := time.Now()
 	proxyEvent := &Event{}
 
 	err := json.Unmarshal(event, proxyEvent)
 	if err != nil {
-		return nil, fmt.Errorf("Parse proxy event: %s", err)
+		return p.fail(proxyEvent, nil, nil, ctx, http.StatusBadRequest, fmt.Errorf("Parse proxy event: %s", err))
 	}
 
 	req, err := buildRequest(proxyEvent, ctx)
 	if err != nil {
-		return nil, fmt.Errorf("Build request: %s", err)
+		return p.fail(proxyEvent, nil, nil, ctx, http.StatusBadRequest, fmt.Errorf("Build request: %s", err))
+	}
+
+	req = stripPrefix(req, proxyEvent, p.options)
+
+	info := p.requestInfo(req)
+	res := p.newResponseWriter(info)
+
+	if info.cors != nil && isPreflight(req) {
+		info.cors.preflight(res, req)
+		res.finish()
+		return p.response(res, proxyEvent), nil
+	}
+
+	if p.options.Stream != nil && proxyEvent.isV2() {
+		if w := p.options.Stream(ctx); w != nil {
+			res.stream = &stream{w: w}
+		}
+	}
+
+	completed, err := p.serve(res, req, info, ctx, start)
+
+	if err != nil {
+		if res.stream != nil && !p.options.LambdaErrors {
+			failed := p.renderError(info, req, ctx, http.StatusInternalServerError, err)
+			return nil, res.stream.abort(failed)
+		}
+		return p.fail(proxyEvent, req, info, ctx, http.StatusInternalServerError, err)
+	}
+
+	if !completed {
+		timeout := p.newResponseWriter(info)
+		writeTimeout(timeout)
+		if res.stream != nil {
+			return nil, res.stream.abort(timeout)
+		}
+		timeout.finish()
+		return p.response(timeout, proxyEvent), nil
+	}
+
+	if res.stream != nil {
+		return nil, res.stream.close(res)
 	}
 
-	res := &ResponseWriter{}
-	p.Handler.ServeHTTP(res, req)
 	res.finish()
+	res = p.limit(res, req, info, proxyEvent)
+
+	return p.response(res, proxyEvent), nil
+}
+
+// requestInfo holds the parts of a request which responses are built from.
+// It is captured before the handler runs, since a handler which times out
+// may keep modifying the request.
+type requestInfo struct {
+	method         string
+	path           string
+	acceptEncoding string
+	origin         string
+	cors           *CORS
+}
+
+// requestInfo returns the requestInfo of req, with the CORS configuration of
+// its stage.
+func (p *handler) requestInfo(req *http.Request) *requestInfo {
+	var stage string
+	if e := EventFromRequest(req); e != nil && e.RequestContext != nil {
+		stage = e.RequestContext.Stage
+	}
+
+	return &requestInfo{
+		method:         req.Method,
+		path:           req.URL.Path,
+		acceptEncoding: req.Header.Get("Accept-Encoding"),
+		origin:         req.Header.Get("Origin"),
+		cors:           p.options.CORS.forStage(stage),
+	}
+}
+
+// newResponseWriter returns a response writer for the request described by
+// info, holding the CORS headers of its response. The info is nil when the
+// request could not be built.
+func (p *handler) newResponseWriter(info *requestInfo) *ResponseWriter {
+	w := &ResponseWriter{options: p.options}
+	if info == nil {
+		return w
+	}
+
+	w.acceptEncoding = info.acceptEncoding
+
+	if info.cors != nil {
+		info.cors.setHeaders(w.Header(), info.origin)
+	}
+
+	return w
+}
+
+// response returns the finished response in the format of the event.
+func (p *handler) response(res *ResponseWriter, e *Event) interface{} {
+	switch {
+	case e.isV2():
+		return res.responseV2()
+	case e.isALB():
+		return res.albResponse(len(e.MultiValueHeaders) > 0)
+	}
+
+	return &res.response
+}
+
+// serve dispatches req to the handler, returning an error when it panics.
+// When the invocation has a deadline, provided by the shim or derived from
+// Options.Timeout and the start of the invocation, the request context is
+// cancelled shortly before it, and serve returns false without waiting for
+// the handler once it passes.
+func (p *handler) serve(w *ResponseWriter, req *http.Request, info *requestInfo, ctx *apex.Context, start time.Time) (bool, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok && p.options.Timeout > 0 {
+		deadline, ok = start.Add(p.options.Timeout), true
+	}
+
+	if !ok {
+		return true, p.serveHTTP(w, req)
+	}
+
+	margin := p.options.TimeoutMargin
+	if margin == 0 {
+		margin = DefaultTimeoutMargin
+	}
+
+	c, cancel := context.WithDeadline(req.Context(), deadline.Add(-margin))
+	defer cancel()
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		err = p.serveHTTP(w, req.WithContext(c))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true, err
+	case <-c.Done():
+	}
+
+	// The handler may have finished just as the deadline passed
+	select {
+	case <-done:
+		return true, err
+	default:
+		log.Printf("timeout handling %s %s", info.method, info.path)
+		return false, nil
+	}
+}
+
+// serveHTTP dispatches req to the handler, recovering from panics.
+func (p *handler) serveHTTP(w http.ResponseWriter, req *http.Request) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			log.Printf("panic handling %s %s: %v\n%s", req.Method, req.URL.Path, v, debug.Stack())
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+
+	p.Handler.ServeHTTP(w, req)
+	return nil
+}
 
-	return &res.response, nil
+// writeTimeout writes an API Gateway style timeout response.
+func writeTimeout(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	w.Write([]byte(`{"message":"Endpoint request timed out"}`))
 }
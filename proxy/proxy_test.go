@@ -0,0 +1,705 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+// Serve apex.Handler assertion.
+var _ apex.Handler = Serve(nil)
+
+func TestServe_multiValue(t *testing.T) {
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"1", "2"}, r.URL.Query()["id"])
+		assert.Equal(t, []string{"a", "b"}, r.Header["X-Tag"])
+		assert.Equal(t, "example.com", r.Host)
+
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		w.Write([]byte("hello"))
+	}))
+
+	event := `{
+		"httpMethod": "GET",
+		"path": "/users",
+		"headers": {"Host": "example.com", "X-Tag": "b"},
+		"multiValueHeaders": {"Host": ["example.com"], "X-Tag": ["a", "b"]},
+		"queryStringParameters": {"id": "2"},
+		"multiValueQueryStringParameters": {"id": ["1", "2"]},
+		"requestContext": {}
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*Response)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "hello", res.Body)
+	assert.Equal(t, "b=2", res.Headers["Set-Cookie"])
+	assert.Equal(t, []string{"a=1", "b=2"}, res.MultiValueHeaders["Set-Cookie"])
+	assert.Equal(t, []string{"text/plain; charset=utf-8"}, res.MultiValueHeaders["Content-Type"])
+}
+
+func TestServe_singleValue(t *testing.T) {
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("id"))
+		assert.Equal(t, "b", r.Header.Get("X-Tag"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	event := `{
+		"httpMethod": "GET",
+		"path": "/users",
+		"headers": {"X-Tag": "b"},
+		"queryStringParameters": {"id": "1"}
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, v.(*Response).StatusCode)
+}
+
+func TestServe_v2(t *testing.T) {
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/my/path", r.URL.Path)
+		assert.Equal(t, []string{"1", "2"}, r.URL.Query()["id"])
+		assert.Equal(t, "a=1; b=2", r.Header.Get("Cookie"))
+		assert.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+
+		c, err := r.Cookie("b")
+		assert.NoError(t, err)
+		assert.Equal(t, "2", c.Value)
+
+		http.SetCookie(w, &http.Cookie{Name: "c", Value: "3"})
+		http.SetCookie(w, &http.Cookie{Name: "d", Value: "4"})
+		w.Header().Add("X-Tag", "a")
+		w.Header().Add("X-Tag", "b")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	event := `{
+		"version": "2.0",
+		"routeKey": "POST /my/path",
+		"rawPath": "/my/path",
+		"rawQueryString": "id=1&id=2",
+		"cookies": ["a=1", "b=2"],
+		"headers": {"content-type": "text/plain", "host": "api.example.com"},
+		"requestContext": {
+			"accountId": "123456789012",
+			"apiId": "api-id",
+			"authorizer": {
+				"jwt": {
+					"claims": {"sub": "user", "scope": "read"},
+					"scopes": ["read"]
+				}
+			},
+			"domainName": "api.example.com",
+			"domainPrefix": "api",
+			"http": {
+				"method": "POST",
+				"path": "/my/path",
+				"protocol": "HTTP/1.1",
+				"sourceIp": "192.0.2.1",
+				"userAgent": "agent"
+			},
+			"requestId": "id",
+			"routeKey": "POST /my/path",
+			"stage": "$default",
+			"time": "12/Mar/2020:19:03:58 +0000",
+			"timeEpoch": 1583348638390
+		},
+		"body": "hello",
+		"isBase64Encoded": false
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*ResponseV2)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "created", res.Body)
+	assert.Equal(t, []string{"c=3", "d=4"}, res.Cookies)
+	assert.Equal(t, "a,b", res.Headers["X-Tag"])
+	assert.NotContains(t, res.Headers, "Set-Cookie")
+}
+
+func TestEvent_String(t *testing.T) {
+	e := &Event{
+		HTTPMethod: "GET",
+		Path:       "/users",
+		Headers:    map[string]string{"Host": "example.com"},
+		RequestContext: &RequestContext{
+			Stage:    "prod",
+			Identity: &Identity{SourceIP: "192.0.2.1"},
+		},
+	}
+
+	assert.JSONEq(t, `{
+		"httpMethod": "GET",
+		"headers": {"Host": "example.com"},
+		"multiValueHeaders": null,
+		"resource": "",
+		"pathParameters": null,
+		"path": "/users",
+		"queryStringParameters": null,
+		"multiValueQueryStringParameters": null,
+		"body": "",
+		"isBase64Encoded": false,
+		"stageVariables": null,
+		"requestContext": {
+			"apiId": "",
+			"resourceId": "",
+			"requestId": "",
+			"httpMethod": "",
+			"resourcePath": "",
+			"accountId": "",
+			"stage": "prod",
+			"identity": {
+				"apiKey": "",
+				"accountId": "",
+				"userAgent": "",
+				"sourceIp": "192.0.2.1",
+				"accessKey": "",
+				"caller": "",
+				"user": "",
+				"userArn": "",
+				"cognitoIdentityId": "",
+				"cognitoIdentityPoolId": "",
+				"cognitoAuthenticationType": "",
+				"cognitoAuthenticationProvider": ""
+			},
+			"authorizer": null
+		}
+	}`, e.String())
+
+	var decoded Event
+	assert.NoError(t, json.Unmarshal([]byte(e.String()), &decoded))
+	assert.Equal(t, "192.0.2.1", decoded.RequestContext.Identity.SourceIP)
+}
+
+func TestEvent_authorizer(t *testing.T) {
+	cases := map[string]map[string]string{
+		`{"claims": {"sub": "cognito"}}`:                    {"sub": "cognito"},
+		`{"jwt": {"claims": {"sub": "jwt"}, "scopes": []}}`: {"sub": "jwt"},
+		`{"lambda": {"user": "lambda"}}`:                    {"user": "lambda"},
+		`{"user": "custom", "jwt": "token"}`:                {"user": "custom", "jwt": "token"},
+	}
+
+	for in, out := range cases {
+		var rc RequestContext
+		assert.NoError(t, json.Unmarshal([]byte(`{"authorizer": `+in+`}`), &rc), in)
+		assert.Equal(t, out, rc.Authorizer, in)
+	}
+}
+
+func TestEvent_authorizerClaims(t *testing.T) {
+	var rc RequestContext
+
+	err := json.Unmarshal([]byte(`{"authorizer": {
+		"principalId": "user|a1b2",
+		"integrationLatency": 12,
+		"admin": true,
+		"level": 3,
+		"name": "Tobi"
+	}}`), &rc)
+	assert.NoError(t, err)
+	assert.Equal(t, "user|a1b2", rc.PrincipalID)
+	assert.Equal(t, 12, rc.IntegrationLatency)
+	assert.Equal(t, true, rc.Claims["admin"])
+	assert.Equal(t, float64(3), rc.Claims["level"])
+	assert.Equal(t, map[string]string{
+		"principalId":        "user|a1b2",
+		"integrationLatency": "12",
+		"admin":              "true",
+		"level":              "3",
+		"name":               "Tobi",
+	}, rc.Authorizer)
+
+	rc = RequestContext{}
+	err = json.Unmarshal([]byte(`{"authorizer": {"jwt": {
+		"claims": {"sub": "a1b2", "cognito:groups": ["admin", "users"], "exp": 1700000000},
+		"scopes": ["read", "write"]
+	}}}`), &rc)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, rc.Scopes)
+	assert.Equal(t, []interface{}{"admin", "users"}, rc.Claims["cognito:groups"])
+	assert.Equal(t, `["admin","users"]`, rc.Authorizer["cognito:groups"])
+	assert.Equal(t, "1700000000", rc.Authorizer["exp"])
+	assert.Equal(t, "a1b2", rc.Authorizer["sub"])
+
+	b, err := json.Marshal(&rc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"authorizer":{"jwt":{"claims":{"sub":"a1b2","cognito:groups":["admin","users"],"exp":1700000000},"scopes":["read","write"]}}`)
+
+	// Changes to the decoded fields are kept
+	rc.Claims["sub"] = "c3d4"
+	rc.Scopes = []string{"read"}
+	b, err = json.Marshal(&rc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"authorizer":{"jwt":{"claims":{"cognito:groups":["admin","users"],"exp":1700000000,"sub":"c3d4"},"scopes":["read"]}}`)
+
+	var edited RequestContext
+	assert.NoError(t, json.Unmarshal(b, &edited))
+	assert.Equal(t, "c3d4", edited.Authorizer["sub"])
+	assert.Equal(t, []string{"read"}, edited.Scopes)
+
+	rc = RequestContext{}
+	err = json.Unmarshal([]byte(`{"authorizer": {"claims": {"sub": "a1b2", "email": "tobi@example.com"}}}`), &rc)
+	assert.NoError(t, err)
+	rc.Authorizer["sub"] = "c3d4"
+	delete(rc.Authorizer, "email")
+	b, err = json.Marshal(&rc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"authorizer":{"claims":{"sub":"c3d4"}}`)
+
+	rc = RequestContext{}
+	err = json.Unmarshal([]byte(`{"authorizer": null}`), &rc)
+	assert.NoError(t, err)
+	assert.Nil(t, rc.Authorizer)
+	assert.Nil(t, rc.RawAuthorizer)
+}
+
+func TestServe_alb(t *testing.T) {
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/lambda", r.URL.Path)
+		assert.Equal(t, []string{"a b", "c&d"}, r.URL.Query()["q"])
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	event := `{
+		"requestContext": {
+			"elb": {
+				"targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/lambda/50dc6c495c0c9188"
+			}
+		},
+		"httpMethod": "GET",
+		"path": "/lambda",
+		"multiValueQueryStringParameters": {"q": ["a%20b", "c%26d"]},
+		"multiValueHeaders": {"host": ["lambda-alb.example.com"]},
+		"body": "",
+		"isBase64Encoded": false
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*ALBResponse)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, "404 Not Found", res.StatusDescription)
+	assert.Equal(t, []string{"a=1", "b=2"}, res.MultiValueHeaders["Set-Cookie"])
+	assert.Nil(t, res.Headers)
+
+	event = `{
+		"requestContext": {"elb": {"targetGroupArn": "arn"}},
+		"httpMethod": "GET",
+		"path": "/lambda",
+		"queryStringParameters": {"q": "a%20b"},
+		"headers": {"host": "lambda-alb.example.com"}
+	}`
+
+	h = Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "a b", r.URL.Query().Get("q"))
+		w.Write([]byte("ok"))
+	}))
+
+	v, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	res = v.(*ALBResponse)
+	assert.Equal(t, "200 OK", res.StatusDescription)
+	assert.Equal(t, "ok", res.Body)
+	assert.Equal(t, "text/plain; charset=utf-8", res.Headers["Content-Type"])
+	assert.Nil(t, res.MultiValueHeaders)
+}
+
+func TestServe_functionURL(t *testing.T) {
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "/items/1", r.URL.Path)
+		assert.Equal(t, "abcdefg.lambda-url.us-east-1.on.aws", r.Host)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	event := `{
+		"version": "2.0",
+		"routeKey": "$default",
+		"rawPath": "/items/1",
+		"rawQueryString": "",
+		"headers": {"host": "abcdefg.lambda-url.us-east-1.on.aws"},
+		"requestContext": {
+			"accountId": "123456789012",
+			"apiId": "abcdefg",
+			"authorizer": {
+				"iam": {
+					"accessKey": "AKIA",
+					"accountId": "111122223333",
+					"callerId": "AIDA",
+					"cognitoIdentity": null,
+					"principalOrgId": "o-abc",
+					"userArn": "arn:aws:iam::111122223333:user/example-user",
+					"userId": "AIDA"
+				}
+			},
+			"domainName": "abcdefg.lambda-url.us-east-1.on.aws",
+			"domainPrefix": "abcdefg",
+			"http": {
+				"method": "PUT",
+				"path": "/items/1",
+				"protocol": "HTTP/1.1",
+				"sourceIp": "203.0.113.1",
+				"userAgent": "agent"
+			},
+			"requestId": "id",
+			"routeKey": "$default",
+			"stage": "$default",
+			"time": "12/Mar/2020:19:03:58 +0000",
+			"timeEpoch": 1583348638390
+		},
+		"isBase64Encoded": false
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*ResponseV2)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `{"ok":true}`, res.Body)
+	assert.False(t, res.IsBase64Encoded)
+
+	var e Event
+	assert.NoError(t, json.Unmarshal([]byte(event), &e))
+	rc := e.RequestContext
+	assert.Equal(t, "arn:aws:iam::111122223333:user/example-user", rc.IAM.UserARN)
+	assert.Equal(t, "o-abc", rc.IAM.PrincipalOrgID)
+	assert.Nil(t, rc.Authorizer)
+
+	b, err := json.Marshal(rc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"authorizer":{"iam":{"accessKey":"AKIA"`)
+}
+
+func TestServe_context(t *testing.T) {
+	lambdaCtx := &apex.Context{RequestID: "request"}
+
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := EventFromRequest(r)
+		assert.Equal(t, "/users/{id}", e.Resource)
+		assert.Equal(t, "body", e.Body)
+		assert.Equal(t, lambdaCtx, ContextFromRequest(r))
+		assert.Equal(t, "42", PathParam(r, "id"))
+		assert.Equal(t, "", PathParam(r, "missing"))
+		assert.Equal(t, "users", StageVariable(r, "table"))
+		assert.Equal(t, map[string]string{"sub": "user"}, Authorizer(r))
+		assert.Empty(t, r.Header.Get("X-ApiGatewayProxy-Event"))
+		assert.Empty(t, r.Header.Get("X-ApiGatewayProxy-Context"))
+	}))
+
+	event := `{
+		"httpMethod": "GET",
+		"resource": "/users/{id}",
+		"path": "/users/42",
+		"pathParameters": {"id": "42"},
+		"stageVariables": {"table": "users"},
+		"headers": {"X-ApiGatewayProxy-Event": "{}", "X-ApiGatewayProxy-Context": "{}"},
+		"requestContext": {"authorizer": {"claims": {"sub": "user"}}},
+		"body": "body"
+	}`
+
+	_, err := h.Handle(json.RawMessage(event), lambdaCtx)
+	assert.NoError(t, err)
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	assert.Nil(t, EventFromRequest(r))
+	assert.Nil(t, ContextFromRequest(r))
+	assert.Equal(t, "", PathParam(r, "id"))
+	assert.Equal(t, "", StageVariable(r, "table"))
+	assert.Nil(t, Authorizer(r))
+}
+
+func TestServe_connection(t *testing.T) {
+	var req *http.Request
+
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	}))
+
+	event := `{
+		"httpMethod": "POST",
+		"path": "/users",
+		"queryStringParameters": {"q": "a b"},
+		"headers": {"Host": "example.com"},
+		"requestContext": {"identity": {"sourceIp": "203.0.113.1"}},
+		"body": "aGVsbG8=",
+		"isBase64Encoded": true
+	}`
+
+	_, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.1:0", req.RemoteAddr)
+	assert.Equal(t, "/users?q=a+b", req.RequestURI)
+	assert.Equal(t, int64(5), req.ContentLength)
+	assert.Equal(t, "HTTP/1.1", req.Proto)
+	assert.Equal(t, 1, req.ProtoMajor)
+	assert.Equal(t, 1, req.ProtoMinor)
+	assert.NotNil(t, req.TLS)
+	assert.Equal(t, "example.com", req.TLS.ServerName)
+
+	event = `{
+		"version": "2.0",
+		"rawPath": "/",
+		"headers": {"host": "example.com"},
+		"requestContext": {"http": {"method": "GET", "protocol": "HTTP/2.0", "sourceIp": "2001:db8::1"}}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:0", req.RemoteAddr)
+	assert.Equal(t, "HTTP/2.0", req.Proto)
+	assert.Equal(t, 2, req.ProtoMajor)
+	assert.Equal(t, int64(0), req.ContentLength)
+
+	event = `{
+		"httpMethod": "GET",
+		"path": "/",
+		"headers": {"host": "example.com", "x-forwarded-for": "198.51.100.1, 10.0.0.1"},
+		"requestContext": {"elb": {"targetGroupArn": "arn"}}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:0", req.RemoteAddr)
+	assert.Nil(t, req.TLS)
+
+	// Spoofed by the client
+	event = `{
+		"httpMethod": "GET",
+		"path": "/",
+		"multiValueHeaders": {"host": ["example.com"], "x-forwarded-for": ["127.0.0.1", "203.0.113.7"]},
+		"requestContext": {"elb": {"targetGroupArn": "arn"}}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:0", req.RemoteAddr)
+
+	// Only trusted from load balancers
+	event = `{
+		"httpMethod": "GET",
+		"path": "/",
+		"headers": {"host": "example.com", "x-forwarded-for": "203.0.113.7"}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", req.RemoteAddr)
+
+	event = `{
+		"httpMethod": "GET",
+		"path": "/",
+		"headers": {"host": "example.com", "x-forwarded-proto": "https"},
+		"requestContext": {"elb": {"targetGroupArn": "arn"}}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.NotNil(t, req.TLS)
+}
+
+func TestServe_deadline(t *testing.T) {
+	event := json.RawMessage(`{"httpMethod": "GET", "path": "/"}`)
+
+	t.Run("completed", func(t *testing.T) {
+		h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, ok := r.Context().Deadline()
+			assert.True(t, ok)
+			w.Write([]byte(RequestID(r)))
+		}))
+
+		ctx := &apex.Context{
+			RequestID:  "abc",
+			DeadlineMs: time.Now().Add(time.Minute).UnixNano() / int64(time.Millisecond),
+		}
+
+		v, err := h.Handle(event, ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, v.(*Response).StatusCode)
+		assert.Equal(t, "abc", v.(*Response).Body)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("too late"))
+		}), Options{TimeoutMargin: 10 * time.Millisecond})
+
+		ctx := &apex.Context{
+			DeadlineMs: time.Now().Add(50*time.Millisecond).UnixNano() / int64(time.Millisecond),
+		}
+
+		v, err := h.Handle(event, ctx)
+		assert.NoError(t, err)
+
+		res := v.(*Response)
+		assert.Equal(t, 504, res.StatusCode)
+		assert.Equal(t, `{"message":"Endpoint request timed out"}`, res.Body)
+	})
+
+	t.Run("timeout modifying the request", func(t *testing.T) {
+		h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			for end := time.Now().Add(50 * time.Millisecond); time.Now().Before(end); {
+				r.Header.Set("Origin", "https://evil.com")
+				r.Header.Set("Accept-Encoding", "br")
+				r.URL.Path = "/other"
+			}
+		}), Options{
+			TimeoutMargin: 10 * time.Millisecond,
+			CORS:          &CORS{AllowOrigins: []string{"https://example.com"}},
+		})
+
+		ctx := &apex.Context{
+			DeadlineMs: time.Now().Add(50*time.Millisecond).UnixNano() / int64(time.Millisecond),
+		}
+
+		v, err := h.Handle(json.RawMessage(`{"httpMethod": "GET", "path": "/", "headers": {"Origin": "https://example.com"}}`), ctx)
+		assert.NoError(t, err)
+
+		res := v.(*Response)
+		assert.Equal(t, 504, res.StatusCode)
+		assert.Equal(t, "https://example.com", res.Headers["Access-Control-Allow-Origin"])
+	})
+
+	t.Run("no deadline", func(t *testing.T) {
+		h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, ok := r.Context().Deadline()
+			assert.False(t, ok)
+			w.Write([]byte("ok"))
+		}))
+
+		v, err := h.Handle(event, &apex.Context{})
+		assert.NoError(t, err)
+		assert.Equal(t, 200, v.(*Response).StatusCode)
+	})
+
+	t.Run("configured timeout", func(t *testing.T) {
+		h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			time.Sleep(50 * time.Millisecond)
+		}), Options{Timeout: 60 * time.Millisecond, TimeoutMargin: 10 * time.Millisecond})
+
+		start := time.Now()
+		v, err := h.Handle(event, &apex.Context{})
+		assert.NoError(t, err)
+		assert.Equal(t, 504, v.(*Response).StatusCode)
+		assert.True(t, time.Since(start) < 100*time.Millisecond)
+	})
+}
+
+// clientCertPEM returns a self-signed PEM encoded certificate for cn.
+func clientCertPEM(t *testing.T, cn string) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestServe_clientCert(t *testing.T) {
+	var req *http.Request
+
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+	}))
+
+	certPEM, err := json.Marshal(clientCertPEM(t, "client.example.com"))
+	assert.NoError(t, err)
+
+	event := `{
+		"httpMethod": "GET",
+		"path": "/",
+		"headers": {"Host": "api.example.com"},
+		"requestContext": {
+			"protocol": "HTTP/2.0",
+			"path": "/v1/",
+			"domainName": "api.example.com",
+			"requestTimeEpoch": 1583348638390,
+			"identity": {
+				"sourceIp": "192.0.2.1",
+				"principalOrgId": "o-abc",
+				"clientCert": {
+					"clientCertPem": ` + string(certPEM) + `,
+					"subjectDN": "CN=client.example.com",
+					"issuerDN": "CN=client.example.com",
+					"serialNumber": "42",
+					"validity": {"notBefore": "May 28 12:30:02 2019 GMT", "notAfter": "Aug  5 09:36:04 2021 GMT"}
+				}
+			}
+		}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+
+	rc := EventFromRequest(req).RequestContext
+	assert.Equal(t, "o-abc", rc.Identity.PrincipalOrgID)
+	assert.Equal(t, "/v1/", rc.Path)
+	assert.Equal(t, "api.example.com", rc.DomainName)
+	assert.Equal(t, int64(1583348638390), rc.RequestTimeEpoch)
+	assert.Equal(t, "CN=client.example.com", rc.ClientCert().SubjectDN)
+	assert.Equal(t, "Aug  5 09:36:04 2021 GMT", rc.ClientCert().Validity.NotAfter)
+	assert.Equal(t, "HTTP/2.0", req.Proto)
+	assert.Len(t, req.TLS.PeerCertificates, 1)
+	assert.Equal(t, "client.example.com", req.TLS.PeerCertificates[0].Subject.CommonName)
+
+	event = `{
+		"version": "2.0",
+		"rawPath": "/",
+		"requestContext": {
+			"http": {"method": "GET"},
+			"authentication": {"clientCert": {"clientCertPem": ` + string(certPEM) + `}}
+		}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Len(t, req.TLS.PeerCertificates, 1)
+
+	event = `{
+		"httpMethod": "GET",
+		"path": "/",
+		"requestContext": {"identity": {"clientCert": {"clientCertPem": "invalid"}}}
+	}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.NotNil(t, req.TLS)
+	assert.Empty(t, req.TLS.PeerCertificates)
+}
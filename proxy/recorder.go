@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/go-apex"
+)
+
+// EventOptions configures the events created by NewEventFromRequest.
+type EventOptions struct {
+	// Version is "2.0" for HTTP API payload format 2.0 events, REST API
+	// events are created otherwise.
+	Version string
+
+	// Resource is the resource template, such as "/users/{id}", or the route
+	// path of an HTTP API. Defaults to the request path.
+	Resource string
+
+	// PathParameters are the values of the resource template placeholders.
+	PathParameters map[string]string
+
+	// Stage is the deployment stage, defaults to "$default" for payload
+	// format 2.0.
+	Stage string
+
+	// StageVariables of the deployment stage.
+	StageVariables map[string]string
+
+	// BinaryMediaTypes are the request content types which are Base64
+	// encoded, such as "image/png", "image/*" or "*/*".
+	BinaryMediaTypes []string
+}
+
+// NewEventFromRequest returns the proxy event API Gateway would send for r,
+// reading its body.
+func NewEventFromRequest(r *http.Request, opts EventOptions) (*Event, error) {
+	var body []byte
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading body: %s", err)
+		}
+		body = b
+	}
+
+	resource := opts.Resource
+	if resource == "" {
+		resource = r.URL.Path
+	}
+
+	header := r.Header
+	if r.Host != "" {
+		header = make(http.Header, len(r.Header)+1)
+		for k, v := range r.Header {
+			header[k] = v
+		}
+		header.Set("Host", r.Host)
+	}
+
+	e := &Event{
+		Headers:        make(map[string]string),
+		StageVariables: opts.StageVariables,
+		Body:           string(body),
+		RequestContext: &RequestContext{
+			RequestID: newRequestID(),
+			Stage:     opts.Stage,
+		},
+	}
+
+	if len(opts.PathParameters) > 0 {
+		e.PathParameters = opts.PathParameters
+	}
+
+	if matchMediaTypes(opts.BinaryMediaTypes, r.Header.Get("Content-Type")) {
+		e.Body = base64.StdEncoding.EncodeToString(body)
+		e.IsBase64Encoded = true
+	}
+
+	if opts.Version == "2.0" {
+		if e.RequestContext.Stage == "" {
+			e.RequestContext.Stage = "$default"
+		}
+
+		now := time.Now()
+		e.Version = opts.Version
+		e.RouteKey = r.Method + " " + resource
+		e.RawPath = r.URL.EscapedPath()
+		e.RawQueryString = r.URL.RawQuery
+		e.RequestContext.RouteKey = e.RouteKey
+		e.RequestContext.Time = now.UTC().Format("02/Jan/2006:15:04:05 -0700")
+		e.RequestContext.TimeEpoch = now.UnixNano() / int64(time.Millisecond)
+		e.RequestContext.HTTP = &HTTP{
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Protocol:  r.Proto,
+			SourceIP:  remoteIP(r.RemoteAddr),
+			UserAgent: r.UserAgent(),
+		}
+
+		for k, vs := range header {
+			if k == "Cookie" {
+				for _, v := range vs {
+					e.Cookies = append(e.Cookies, strings.Split(v, "; ")...)
+				}
+				continue
+			}
+			e.Headers[strings.ToLower(k)] = strings.Join(vs, ",")
+		}
+
+		if q := r.URL.Query(); len(q) > 0 {
+			e.QueryStringParameters = make(map[string]string)
+			for k, vs := range q {
+				e.QueryStringParameters[k] = strings.Join(vs, ",")
+			}
+		}
+
+		return e, nil
+	}
+
+	e.HTTPMethod = r.Method
+	e.Resource = resource
+	e.Path = r.URL.Path
+	e.MultiValueHeaders = make(map[string][]string)
+	e.RequestContext.HTTPMethod = r.Method
+	e.RequestContext.ResourcePath = resource
+	e.RequestContext.Identity = &Identity{
+		SourceIP:  remoteIP(r.RemoteAddr),
+		UserAgent: r.UserAgent(),
+	}
+
+	for k, vs := range header {
+		e.Headers[k] = vs[len(vs)-1]
+		e.MultiValueHeaders[k] = vs
+	}
+
+	if q := r.URL.Query(); len(q) > 0 {
+		e.QueryStringParameters = make(map[string]string)
+		e.MultiValueQueryStringParameters = q
+		for k, vs := range q {
+			e.QueryStringParameters[k] = vs[len(vs)-1]
+		}
+	}
+
+	return e, nil
+}
+
+// Recorder invokes a handler with the events of requests and decodes its
+// responses, for testing handlers as deployed.
+type Recorder struct {
+	// Handler is invoked with the events.
+	Handler apex.Handler
+
+	// Options configures the events.
+	Options EventOptions
+}
+
+// Do invokes the handler with the event of r and returns its response. An
+// error is returned when the handler fails or its response is malformed.
+func (rec *Recorder) Do(r *http.Request) (*http.Response, error) {
+	e, err := NewEventFromRequest(r, rec.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &apex.Context{
+		RequestID:    e.RequestContext.RequestID,
+		FunctionName: "test",
+	}
+
+	v, err := rec.Handler.Handle(json.RawMessage(b), ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := decodeResponse(v)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Request = r
+	return res, nil
+}
+
+// proxyResponse holds the fields of any proxy response format.
+type proxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Cookies           []string            `json:"cookies"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// decodeResponse returns the HTTP response described by the value returned
+// by a handler, in any of the proxy response formats.
+func decodeResponse(v interface{}) (*http.Response, error) {
+	var res proxyResponse
+
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(b, &res)
+	}
+	if err != nil || res.StatusCode == 0 {
+		return nil, fmt.Errorf("malformed Lambda proxy response: %s", b)
+	}
+
+	body := []byte(res.Body)
+	if res.IsBase64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(res.Body); err != nil {
+			return nil, fmt.Errorf("decoding response body: %s", err)
+		}
+	}
+
+	header := make(http.Header)
+
+	for k, v := range res.Headers {
+		if _, ok := res.MultiValueHeaders[k]; !ok {
+			header.Set(k, v)
+		}
+	}
+
+	for k, vs := range res.MultiValueHeaders {
+		header.Del(k)
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+
+	for _, c := range res.Cookies {
+		header.Add("Set-Cookie", c)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEventFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://example.com/users/42?tag=a&tag=b", strings.NewReader(`{"name":"Tobi"}`))
+	r.Header.Add("X-Tag", "a")
+	r.Header.Add("X-Tag", "b")
+
+	e, err := NewEventFromRequest(r, EventOptions{
+		Resource:       "/users/{id}",
+		PathParameters: map[string]string{"id": "42"},
+		Stage:          "prod",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", e.HTTPMethod)
+	assert.Equal(t, "/users/{id}", e.Resource)
+	assert.Equal(t, "/users/42", e.Path)
+	assert.Equal(t, "42", e.PathParameters["id"])
+	assert.Equal(t, []string{"a", "b"}, e.MultiValueHeaders["X-Tag"])
+	assert.Equal(t, "b", e.Headers["X-Tag"])
+	assert.Equal(t, "example.com", e.Headers["Host"])
+	assert.Equal(t, []string{"a", "b"}, e.MultiValueQueryStringParameters["tag"])
+	assert.Equal(t, `{"name":"Tobi"}`, e.Body)
+	assert.False(t, e.IsBase64Encoded)
+	assert.Equal(t, "prod", e.RequestContext.Stage)
+	assert.Equal(t, "192.0.2.1", e.RequestContext.Identity.SourceIP)
+	assert.NotEmpty(t, e.RequestContext.RequestID)
+
+	r = httptest.NewRequest("PUT", "http://example.com/files/a%2Fb?x=1&x=2", bytes.NewReader([]byte{0xff}))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	r.Header.Add("Cookie", "a=1; b=2")
+
+	e, err = NewEventFromRequest(r, EventOptions{
+		Version:          "2.0",
+		Resource:         "/files/{name}",
+		BinaryMediaTypes: []string{"*/*"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0", e.Version)
+	assert.Equal(t, "PUT /files/{name}", e.RouteKey)
+	assert.Equal(t, "/files/a%2Fb", e.RawPath)
+	assert.Equal(t, "x=1&x=2", e.RawQueryString)
+	assert.Equal(t, "1,2", e.QueryStringParameters["x"])
+	assert.Equal(t, []string{"a=1", "b=2"}, e.Cookies)
+	assert.Equal(t, "application/octet-stream", e.Headers["content-type"])
+	assert.NotContains(t, e.Headers, "cookie")
+	assert.Equal(t, "/w==", e.Body)
+	assert.True(t, e.IsBase64Encoded)
+	assert.Equal(t, "$default", e.RequestContext.Stage)
+	assert.Equal(t, "PUT", e.RequestContext.HTTP.Method)
+}
+
+func TestRecorder(t *testing.T) {
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+
+	png := []byte{0x89, 'P', 'N', 'G', 0}
+
+	for _, version := range []string{"", "2.0"} {
+		rec := &Recorder{
+			Handler: h,
+			Options: EventOptions{Version: version, BinaryMediaTypes: []string{"image/*"}},
+		}
+
+		r := httptest.NewRequest("POST", "/upload", bytes.NewReader(png))
+		r.Header.Set("Content-Type", "image/png")
+
+		res, err := rec.Do(r)
+		assert.NoError(t, err, version)
+		body, _ := ioutil.ReadAll(res.Body)
+		assert.Equal(t, http.StatusCreated, res.StatusCode, version)
+		assert.Equal(t, "201 Created", res.Status, version)
+		assert.Equal(t, png, body, version)
+		assert.Equal(t, "image/png", res.Header.Get("Content-Type"), version)
+		assert.Len(t, res.Cookies(), 2, version)
+		assert.Equal(t, r, res.Request, version)
+	}
+}
+
+func TestRecorder_malformed(t *testing.T) {
+	rec := &Recorder{
+		Handler: apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+			return map[string]string{"hello": "world"}, nil
+		}),
+	}
+
+	_, err := rec.Do(httptest.NewRequest("GET", "/", nil))
+	assert.EqualError(t, err, `malformed Lambda proxy response: {"hello":"world"}`)
+}
@@ -23,9 +23,12 @@
 package proxy
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -36,15 +39,10 @@ import (
 // Constructs an http.Request object from a proxyEvent
 func buildRequest(proxyEvent *Event, ctx *apex.Context) (*http.Request, error) {
 	// Reconstruct the request URL
-	u, err := url.Parse(proxyEvent.Path)
+	u, err := requestURL(proxyEvent)
 	if err != nil {
 		return nil, fmt.Errorf("Parse request path: %s", err)
 	}
-	q := u.Query()
-	for k, v := range proxyEvent.QueryStringParameters {
-		q.Set(k, v)
-	}
-	u.RawQuery = q.Encode()
 
 	// Decode the request body
 	dec := proxyEvent.Body
@@ -57,29 +55,156 @@ func buildRequest(proxyEvent *Event, ctx *apex.Context) (*http.Request, error) {
 	}
 
 	// Create a new request object
-	req, err := http.NewRequest(proxyEvent.HTTPMethod, u.String(), strings.NewReader(dec))
+	method := proxyEvent.HTTPMethod
+	if proxyEvent.isV2() && proxyEvent.RequestContext != nil && proxyEvent.RequestContext.HTTP != nil {
+		method = proxyEvent.RequestContext.HTTP.Method
+	}
+
+	req, err := http.NewRequest(method, u.String(), strings.NewReader(dec))
 	if err != nil {
 		return nil, fmt.Errorf("Create request: %s", err)
 	}
 
 	// Copy event headers to request
-	for k, v := range proxyEvent.Headers {
-		req.Header.Set(k, v)
+	if len(proxyEvent.MultiValueHeaders) > 0 {
+		for k, vs := range proxyEvent.MultiValueHeaders {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+	} else {
+		for k, v := range proxyEvent.Headers {
+			req.Header.Set(k, v)
+		}
 	}
 
-	// Store the original event and context in the request headers
-	proxyEvent.Body = "... truncated"
-	hbody, err := json.Marshal(proxyEvent)
-	if err != nil {
-		return nil, fmt.Errorf("Marshal proxy event: %s", err)
-	}
-	req.Header.Set("X-ApiGatewayProxy-Event", string(hbody))
-	if ctx != nil {
-		req.Header.Set("X-ApiGatewayProxy-Context", string(ctx.ClientContext))
+	// Payload format 2.0 moves cookies out of the headers
+	if len(proxyEvent.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(proxyEvent.Cookies, "; "))
 	}
 
+	// Strip headers formerly used to pass the event, which clients could
+	// spoof, and store the original event and context in the request context
+	req.Header.Del("X-ApiGatewayProxy-Event")
+	req.Header.Del("X-ApiGatewayProxy-Context")
+	req = req.WithContext(newContext(req.Context(), proxyEvent, ctx))
+
 	// Map additional request information
-	req.Host = proxyEvent.Headers["Host"]
+	req.Host = req.Header.Get("Host")
+	req.RequestURI = u.RequestURI()
+	req.ContentLength = int64(len(dec))
+
+	if ip := sourceIP(proxyEvent, req); ip != "" {
+		req.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
+
+	req.Proto = "HTTP/1.1"
+	if rc := proxyEvent.RequestContext; rc != nil {
+		if rc.HTTP != nil && rc.HTTP.Protocol != "" {
+			req.Proto = rc.HTTP.Protocol
+		} else if rc.Protocol != "" {
+			req.Proto = rc.Protocol
+		}
+	}
+	if major, minor, ok := http.ParseHTTPVersion(req.Proto); ok {
+		req.ProtoMajor, req.ProtoMinor = major, minor
+	}
+
+	if requestScheme(proxyEvent, req) == "https" {
+		req.TLS = &tls.ConnectionState{
+			HandshakeComplete: true,
+			ServerName:        strings.SplitN(req.Host, ":", 2)[0],
+		}
+
+		// API Gateway verified the client certificate of mutual TLS requests
+		if rc := proxyEvent.RequestContext; rc != nil && rc.ClientCert() != nil {
+			cert, err := rc.ClientCert().Certificate()
+			if err != nil {
+				log.Printf("error parsing client certificate: %s", err)
+			} else {
+				req.TLS.PeerCertificates = []*x509.Certificate{cert}
+			}
+		}
+	}
 
 	return req, nil
 }
+
+// sourceIP returns the IP address of the client.
+func sourceIP(e *Event, req *http.Request) string {
+	if rc := e.RequestContext; rc != nil {
+		if rc.HTTP != nil && rc.HTTP.SourceIP != "" {
+			return rc.HTTP.SourceIP
+		}
+		if rc.Identity != nil && rc.Identity.SourceIP != "" {
+			return rc.Identity.SourceIP
+		}
+	}
+
+	// Application Load Balancers only pass the client address in a header,
+	// appended to any X-Forwarded-For value sent by the client
+	if e.isALB() {
+		return lastHeader(req, "X-Forwarded-For")
+	}
+
+	return ""
+}
+
+// requestScheme returns the scheme used by the client. API Gateway and function
+// URLs only accept HTTPS, while load balancers may also listen on HTTP and
+// append the scheme to X-Forwarded-Proto.
+func requestScheme(e *Event, req *http.Request) string {
+	if !e.isALB() {
+		return "https"
+	}
+
+	if v := lastHeader(req, "X-Forwarded-Proto"); v != "" {
+		return strings.ToLower(v)
+	}
+
+	return "http"
+}
+
+// requestURL returns the path and query of the request described by e.
+func requestURL(e *Event) (*url.URL, error) {
+	if e.isV2() {
+		u, err := url.Parse(e.RawPath)
+		if err != nil {
+			return nil, err
+		}
+		u.RawQuery = e.RawQueryString
+		return u, nil
+	}
+
+	u, err := url.Parse(e.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Application Load Balancers pass query strings without decoding them
+	unescape := func(s string) string { return s }
+	if e.isALB() {
+		unescape = func(s string) string {
+			if v, err := url.QueryUnescape(s); err == nil {
+				return v
+			}
+			return s
+		}
+	}
+
+	q := u.Query()
+	if len(e.MultiValueQueryStringParameters) > 0 {
+		for k, vs := range e.MultiValueQueryStringParameters {
+			for _, v := range vs {
+				q.Add(unescape(k), unescape(v))
+			}
+		}
+	} else {
+		for k, v := range e.QueryStringParameters {
+			q.Set(unescape(k), unescape(v))
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return u, nil
+}
@@ -3,9 +3,12 @@ package proxy
 import (
 	"bytes"
 	"encoding/base64"
+	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"regexp"
+	"strings"
 )
 
 // DefaultTextContentTypes specifies the content types that will not be Base64 encoded
@@ -18,7 +21,6 @@ var DefaultTextContentTypes = []string{
 	`application/.*\+xml`,
 }
 
-var textContentTypes []string
 var textContentTypesRegexp *regexp.Regexp
 
 func init() {
@@ -31,14 +33,11 @@ func init() {
 // SetTextContentTypes configures the proxy package to skip Base64 encoding of the response
 // body for responses with a Content-Type header matching one of the provided types.
 // Each type provided is a regular expression pattern.
+//
+// The setting applies to every handler without Options.TextContentTypes, prefer
+// configuring each handler with NewHandler.
 func SetTextContentTypes(types []string) error {
-	pattern := "(" + types[0]
-	for _, t := range types {
-		pattern += "|" + t
-	}
-	pattern += `)\b.*`
-
-	r, err := regexp.Compile(pattern)
+	r, err := compileTextContentTypes(types)
 	if err != nil {
 		return err
 	}
@@ -47,24 +46,59 @@ func SetTextContentTypes(types []string) error {
 	return nil
 }
 
+// compileTextContentTypes returns a regular expression matching any of the
+// types, or nil when there are none.
+func compileTextContentTypes(types []string) (*regexp.Regexp, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	return regexp.Compile("(" + strings.Join(types, "|") + `)\b.*`)
+}
+
 // Response defines parameters for a well formed response AWS Lambda should
 // return to Amazon API Gateway.
 // Originally from https://github.com/eawsy/aws-lambda-go-net/blob/master/service/lambda/runtime/net/apigatewayproxy/server.go
 type Response struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body,omitempty"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// ResponseV2 defines parameters for a well formed response AWS Lambda should
+// return to an Amazon API Gateway HTTP API using payload format 2.0.
+type ResponseV2 struct {
 	StatusCode      int               `json:"statusCode"`
 	Headers         map[string]string `json:"headers,omitempty"`
+	Cookies         []string          `json:"cookies,omitempty"`
 	Body            string            `json:"body,omitempty"`
 	IsBase64Encoded bool              `json:"isBase64Encoded"`
 }
 
+// ALBResponse defines parameters for a well formed response AWS Lambda should
+// return to an Application Load Balancer.
+type ALBResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	StatusDescription string              `json:"statusDescription"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
 // ResponseWriter implements the http.ResponseWriter interface and
 // collects the results of an HTTP request in an API Gateway proxy
-// response object.
+// response object, or streams them when Options.Stream is set.
 type ResponseWriter struct {
 	response       Response
 	output         bytes.Buffer
 	headers        http.Header
 	headersWritten bool
+	options        *options
+	stream         *stream
+	acceptEncoding string
 }
 
 // Header returns the header map that will be sent by
@@ -103,9 +137,24 @@ func (w *ResponseWriter) Write(bs []byte) (int, error) {
 	if !w.headersWritten {
 		w.WriteHeader(http.StatusOK)
 	}
+	if w.stream != nil {
+		return w.stream.write(w, bs)
+	}
 	return w.output.Write(bs)
 }
 
+// Flush implements http.Flusher. When the response is streamed the status,
+// headers and body written so far are sent to the client, otherwise the
+// response is buffered until the handler returns.
+func (w *ResponseWriter) Flush() {
+	if !w.headersWritten {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.stream != nil {
+		w.stream.flush(w)
+	}
+}
+
 // WriteHeader sends an HTTP response header with status code.
 // If WriteHeader is not called explicitly, the first call to Write
 // will trigger an implicit WriteHeader(http.StatusOK).
@@ -118,30 +167,36 @@ func (w *ResponseWriter) WriteHeader(status int) {
 
 	w.response.StatusCode = status
 
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	// Headers keeps the last value for clients of the single value format,
+	// while MultiValueHeaders preserves duplicates such as Set-Cookie.
 	finalHeaders := make(map[string]string)
+	multiHeaders := make(map[string][]string)
 	for k, v := range w.headers {
 		if len(v) > 0 {
 			finalHeaders[k] = v[len(v)-1]
+			multiHeaders[k] = append([]string(nil), v...)
 		}
 	}
 
-	if value, ok := finalHeaders["Content-Type"]; !ok || value == "" {
-		finalHeaders["Content-Type"] = "text/plain; charset=utf-8"
-	}
-
 	w.response.Headers = finalHeaders
+	w.response.MultiValueHeaders = multiHeaders
 
 	w.headersWritten = true
 }
 
-// finish writes the accumulated output to the response.Body
+// finish writes the accumulated output to the response.Body. Like net/http
+// the status defaults to 200 when the handler wrote nothing.
 func (w *ResponseWriter) finish() {
+	if !w.headersWritten {
+		w.WriteHeader(http.StatusOK)
+	}
 
-	// Determine if we should Base64 encode the output
-	contentType := w.response.Headers["Content-Type"]
-
-	// Only encode text content types without base64 encoding
-	w.response.IsBase64Encoded = !textContentTypesRegexp.MatchString(contentType)
+	w.compress()
+	w.response.IsBase64Encoded = w.isBinary()
 
 	if w.response.IsBase64Encoded {
 		w.response.Body = base64.StdEncoding.EncodeToString(w.output.Bytes())
@@ -149,3 +204,95 @@ func (w *ResponseWriter) finish() {
 		w.response.Body = w.output.String()
 	}
 }
+
+// isBinary reports whether the output must be Base64 encoded. Encoded output,
+// such as gzip compressed JSON, and binary media types are always encoded,
+// while only text content types are passed through as is.
+func (w *ResponseWriter) isBinary() bool {
+	if enc := w.Header().Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return true
+	}
+
+	contentType := w.Header().Get("Content-Type")
+	text := textContentTypesRegexp
+
+	if w.options != nil {
+		if matchMediaTypes(w.options.BinaryMediaTypes, contentType) {
+			return true
+		}
+		if w.options.text != nil {
+			text = w.options.text
+		}
+	}
+
+	return text == nil || !text.MatchString(contentType)
+}
+
+// matchMediaTypes reports whether contentType matches one of the media type
+// patterns, which may use "*" for the type or subtype.
+func matchMediaTypes(patterns []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	mtype, msub := splitMediaType(mediaType)
+
+	for _, p := range patterns {
+		ptype, psub := splitMediaType(strings.ToLower(p))
+		if (ptype == "*" || ptype == mtype) && (psub == "*" || psub == msub) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitMediaType splits a media type into type and subtype.
+func splitMediaType(mediaType string) (string, string) {
+	if i := strings.Index(mediaType, "/"); i >= 0 {
+		return mediaType[:i], mediaType[i+1:]
+	}
+	return mediaType, ""
+}
+
+// responseV2 returns the accumulated response in payload format 2.0, where
+// duplicate headers are joined with commas and cookies are listed apart.
+func (w *ResponseWriter) responseV2() *ResponseV2 {
+	res := &ResponseV2{
+		StatusCode:      w.response.StatusCode,
+		Headers:         make(map[string]string),
+		Body:            w.response.Body,
+		IsBase64Encoded: w.response.IsBase64Encoded,
+	}
+
+	for k, v := range w.response.MultiValueHeaders {
+		if http.CanonicalHeaderKey(k) == "Set-Cookie" {
+			res.Cookies = append(res.Cookies, v...)
+			continue
+		}
+		res.Headers[k] = strings.Join(v, ",")
+	}
+
+	return res
+}
+
+// albResponse returns the accumulated response for an Application Load
+// Balancer, using multi-value headers only when the target group has them
+// enabled, which is signalled by the event carrying them.
+func (w *ResponseWriter) albResponse(multiValue bool) *ALBResponse {
+	res := &ALBResponse{
+		StatusCode:        w.response.StatusCode,
+		StatusDescription: fmt.Sprintf("%d %s", w.response.StatusCode, http.StatusText(w.response.StatusCode)),
+		Body:              w.response.Body,
+		IsBase64Encoded:   w.response.IsBase64Encoded,
+	}
+
+	if multiValue {
+		res.MultiValueHeaders = w.response.MultiValueHeaders
+	} else {
+		res.Headers = w.response.Headers
+	}
+
+	return res
+}
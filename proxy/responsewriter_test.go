@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+// respond returns the response of h for a GET request.
+func respond(t *testing.T, h apex.Handler) *Response {
+	v, err := h.Handle(json.RawMessage(`{"httpMethod":"GET","path":"/"}`), &apex.Context{})
+	assert.NoError(t, err)
+	return v.(*Response)
+}
+
+// write returns a handler writing body with the given headers.
+func write(body string, headers ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		w.Write([]byte(body))
+	})
+}
+
+func TestNewHandler_emptyResponse(t *testing.T) {
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), Options{
+		CORS: &CORS{AllowOrigins: []string{"https://example.com"}},
+	})
+
+	res := corsRequest(t, h, "GET", "prod", map[string]string{"Origin": "https://example.com"})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "", res.Body)
+	assert.False(t, res.IsBase64Encoded)
+	assert.Equal(t, "https://example.com", res.Headers["Access-Control-Allow-Origin"])
+
+	v, err := h.Handle(json.RawMessage(`{"httpMethod":"GET","path":"/","requestContext":{"elb":{"targetGroupArn":"arn"}}}`), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "200 OK", v.(*ALBResponse).StatusDescription)
+}
+
+func TestNewHandler_textContentTypes(t *testing.T) {
+	res := respond(t, Serve(write(`{}`, "Content-Type", "application/json")))
+	assert.False(t, res.IsBase64Encoded)
+	assert.Equal(t, `{}`, res.Body)
+
+	res = respond(t, Serve(write(`x`, "Content-Type", "application/octet-stream")))
+	assert.True(t, res.IsBase64Encoded)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("x")), res.Body)
+
+	h := NewHandler(write(`x`, "Content-Type", "application/octet-stream"), Options{
+		TextContentTypes: []string{`application/octet-stream`},
+	})
+	assert.False(t, respond(t, h).IsBase64Encoded)
+
+	h = NewHandler(write(`{}`, "Content-Type", "application/json"), Options{
+		TextContentTypes: []string{`text/plain`},
+	})
+	assert.True(t, respond(t, h).IsBase64Encoded)
+
+	assert.Panics(t, func() {
+		NewHandler(nil, Options{TextContentTypes: []string{`(`}})
+	})
+}
+
+func TestNewHandler_binaryMediaTypes(t *testing.T) {
+	h := NewHandler(write(`<svg/>`, "Content-Type", "image/svg+xml"), Options{
+		BinaryMediaTypes: []string{"image/*"},
+	})
+	assert.True(t, respond(t, h).IsBase64Encoded)
+
+	h = NewHandler(write(`{}`, "Content-Type", "application/json; charset=utf-8"), Options{
+		BinaryMediaTypes: []string{"image/*"},
+	})
+	assert.False(t, respond(t, h).IsBase64Encoded)
+}
+
+func TestResponseWriter_contentEncoding(t *testing.T) {
+	for _, enc := range []string{"gzip", "br"} {
+		res := respond(t, Serve(write("\x1f\x8b", "Content-Type", "application/json", "Content-Encoding", enc)))
+		assert.True(t, res.IsBase64Encoded, enc)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x1f\x8b")), res.Body, enc)
+	}
+
+	res := respond(t, Serve(write(`{}`, "Content-Type", "application/json", "Content-Encoding", "identity")))
+	assert.False(t, res.IsBase64Encoded)
+}
+
+func TestSetTextContentTypes(t *testing.T) {
+	defer SetTextContentTypes(DefaultTextContentTypes)
+
+	assert.NoError(t, SetTextContentTypes([]string{`text/csv`}))
+	assert.False(t, respond(t, Serve(write(`a,b`, "Content-Type", "text/csv"))).IsBase64Encoded)
+	assert.True(t, respond(t, Serve(write(`{}`, "Content-Type", "application/json"))).IsBase64Encoded)
+
+	assert.NoError(t, SetTextContentTypes(nil))
+	assert.True(t, respond(t, Serve(write(`a,b`, "Content-Type", "text/csv"))).IsBase64Encoded)
+
+	assert.Error(t, SetTextContentTypes([]string{`(`}))
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// streamDelimiter separates the prelude of a streamed response from its body.
+var streamDelimiter = make([]byte, 8)
+
+// streamPrelude holds the status and headers of a streamed response.
+type streamPrelude struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Cookies    []string          `json:"cookies,omitempty"`
+}
+
+// stream writes a response using Lambda response streaming. Writes made by
+// the handler after a timeout fail with http.ErrHandlerTimeout.
+type stream struct {
+	mu      sync.Mutex
+	w       io.Writer
+	started bool
+	closed  bool
+}
+
+// start writes the prelude built from the headers of rw, once.
+func (s *stream) start(rw *ResponseWriter) error {
+	if s.started {
+		return nil
+	}
+
+	s.started = true
+
+	res := rw.responseV2()
+	b, err := json.Marshal(&streamPrelude{
+		StatusCode: res.StatusCode,
+		Headers:    res.Headers,
+		Cookies:    res.Cookies,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(streamDelimiter)
+	return err
+}
+
+// write writes b to the body.
+func (s *stream) write(rw *ResponseWriter, b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if err := s.start(rw); err != nil {
+		return 0, err
+	}
+
+	return s.w.Write(b)
+}
+
+// flush sends the prelude and the body written so far.
+func (s *stream) flush(rw *ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.start(rw) != nil {
+		return
+	}
+
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close completes the response of rw.
+func (s *stream) close(rw *ResponseWriter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	if !rw.headersWritten {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	return s.start(rw)
+}
+
+// abort completes the response with res, a replacement such as a timeout
+// response, when nothing was sent yet, otherwise the body is cut short.
+func (s *stream) abort(res *ResponseWriter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	if s.started {
+		return nil
+	}
+
+	if err := s.start(res); err != nil {
+		return err
+	}
+
+	_, err := s.w.Write(res.output.Bytes())
+	return err
+}
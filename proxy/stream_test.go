@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+// flushBuffer is a buffer recording its contents at each flush.
+type flushBuffer struct {
+	bytes.Buffer
+	flushes []string
+}
+
+func (b *flushBuffer) Flush() {
+	b.flushes = append(b.flushes, b.String())
+}
+
+var functionURLEvent = json.RawMessage(`{
+	"version": "2.0",
+	"rawPath": "/events",
+	"requestContext": {"http": {"method": "GET"}}
+}`)
+
+// streamTo returns options streaming to w.
+func streamTo(w io.Writer) Options {
+	return Options{
+		Stream: func(*apex.Context) io.Writer { return w },
+	}
+}
+
+func TestNewHandler_stream(t *testing.T) {
+	var buf flushBuffer
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("data: 2\n\n"))
+	}), streamTo(&buf))
+
+	v, err := h.Handle(functionURLEvent, &apex.Context{})
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	prelude := `{"statusCode":200,"headers":{"Content-Type":"text/event-stream"},"cookies":["a=1"]}` + strings.Repeat("\x00", 8)
+	assert.Equal(t, prelude+"data: 1\n\ndata: 2\n\n", buf.String())
+	assert.Equal(t, []string{prelude + "data: 1\n\n"}, buf.flushes)
+}
+
+func TestNewHandler_streamEmpty(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), streamTo(&buf))
+
+	_, err := h.Handle(functionURLEvent, &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"statusCode":200,"headers":{"Content-Type":"text/plain; charset=utf-8"}}`+strings.Repeat("\x00", 8), buf.String())
+}
+
+func TestNewHandler_streamTimeout(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(20 * time.Millisecond)
+		_, err := w.Write([]byte("too late"))
+		assert.Equal(t, http.ErrHandlerTimeout, err)
+	}), Options{
+		TimeoutMargin: 10 * time.Millisecond,
+		Stream:        func(*apex.Context) io.Writer { return &buf },
+	})
+
+	ctx := &apex.Context{
+		DeadlineMs: time.Now().Add(50*time.Millisecond).UnixNano() / int64(time.Millisecond),
+	}
+
+	_, err := h.Handle(functionURLEvent, ctx)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(buf.String(), `{"statusCode":504,`))
+	assert.True(t, strings.HasSuffix(buf.String(), "\x00\x00\x00\x00\x00\x00\x00\x00"+`{"message":"Endpoint request timed out"}`))
+
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestNewHandler_streamFallback(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("b"))
+	}
+
+	// Streaming unavailable for the invocation
+	opts := Options{Stream: func(*apex.Context) io.Writer { return nil }}
+	v, err := NewHandler(http.HandlerFunc(h), opts).Handle(functionURLEvent, &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", v.(*ResponseV2).Body)
+
+	// REST APIs are buffered
+	var buf bytes.Buffer
+	res := respond(t, NewHandler(http.HandlerFunc(h), streamTo(&buf)))
+	assert.Equal(t, "ab", res.Body)
+	assert.Equal(t, 0, buf.Len())
+}
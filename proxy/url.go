@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// stripPrefix removes the configured base path, or the stage, from the path
+// of req, recording the removed prefix for AbsoluteURL.
+func stripPrefix(req *http.Request, e *Event, opts *options) *http.Request {
+	var prefixes []string
+
+	if opts.StripBasePath != "" {
+		prefixes = append(prefixes, "/"+strings.Trim(opts.StripBasePath, "/"))
+	}
+
+	if opts.StripStage && e.RequestContext != nil && e.RequestContext.Stage != "" && e.RequestContext.Stage != "$default" {
+		prefixes = append(prefixes, "/"+e.RequestContext.Stage)
+	}
+
+	for _, prefix := range prefixes {
+		path, ok := trimPathPrefix(req.URL.Path, prefix)
+		if !ok {
+			continue
+		}
+
+		req.URL.Path = path
+		if raw, ok := trimPathPrefix(req.URL.RawPath, prefix); ok {
+			req.URL.RawPath = raw
+		} else {
+			req.URL.RawPath = ""
+		}
+
+		return req.WithContext(context.WithValue(req.Context(), basePathKey, prefix))
+	}
+
+	return req
+}
+
+// trimPathPrefix removes prefix from path when it is a whole segment.
+func trimPathPrefix(path, prefix string) (string, bool) {
+	if path == prefix {
+		return "/", true
+	}
+
+	if strings.HasPrefix(path, prefix+"/") {
+		return path[len(prefix):], true
+	}
+
+	return path, false
+}
+
+// AbsoluteURL returns the absolute URL of path as seen by the client of r,
+// for use in redirects and Location headers. The scheme is taken from the TLS
+// state and the host from the domain name of the event or the Host header.
+// Forwarded headers are passed through from the client, so only the port
+// appended to X-Forwarded-Port by load balancers is used. The base path or
+// stage removed from the request path is restored, and the stage is also
+// added for REST APIs invoked through their default execute-api domain,
+// which omits it from the event path.
+func AbsoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	e := EventFromRequest(r)
+
+	host := r.Host
+	if e != nil && e.RequestContext != nil && e.RequestContext.DomainName != "" {
+		host = e.RequestContext.DomainName
+	}
+
+	if e != nil && e.isALB() {
+		if port := lastHeader(r, "X-Forwarded-Port"); port != "" {
+			if _, _, err := net.SplitHostPort(host); err != nil && !isDefaultPort(scheme, port) {
+				host = net.JoinHostPort(host, port)
+			}
+		}
+	}
+
+	prefix, _ := r.Context().Value(basePathKey).(string)
+
+	if prefix == "" && e != nil && !e.isV2() && !e.isALB() && e.RequestContext != nil && e.RequestContext.Stage != "" {
+		if strings.HasSuffix(strings.SplitN(host, ":", 2)[0], ".amazonaws.com") {
+			prefix = "/" + e.RequestContext.Stage
+		}
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return scheme + "://" + host + prefix + path
+}
+
+// lastHeader returns the last comma separated value of header key, across
+// all of its values.
+func lastHeader(r *http.Request, key string) string {
+	vs := r.Header[http.CanonicalHeaderKey(key)]
+	if len(vs) == 0 {
+		return ""
+	}
+
+	v := vs[len(vs)-1]
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// isDefaultPort reports whether port is the default port of scheme.
+func isDefaultPort(scheme, port string) bool {
+	return (scheme == "https" && port == "443") || (scheme == "http" && port == "80")
+}
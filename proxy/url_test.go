@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHandler_stripBasePath(t *testing.T) {
+	var path, location string
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		location = AbsoluteURL(r, "/users/2")
+	}), Options{StripBasePath: "/api/"})
+
+	event := `{
+		"httpMethod": "GET",
+		"path": "/api/users/1",
+		"headers": {"Host": "example.com", "X-Forwarded-Proto": "https", "X-Forwarded-Port": "443"},
+		"requestContext": {"stage": "prod"}
+	}`
+
+	_, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/1", path)
+	assert.Equal(t, "https://example.com/api/users/2", location)
+
+	event = `{"httpMethod": "GET", "path": "/apiv2/users", "headers": {"Host": "example.com"}}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/apiv2/users", path)
+	assert.Equal(t, "https://example.com/users/2", location)
+
+	event = `{"httpMethod": "GET", "path": "/api", "headers": {"Host": "example.com"}}`
+
+	_, err = h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/", path)
+}
+
+func TestNewHandler_stripStage(t *testing.T) {
+	var path, location string
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		location = AbsoluteURL(r, "login")
+	}), Options{StripStage: true})
+
+	event := `{
+		"version": "2.0",
+		"rawPath": "/prod/users",
+		"headers": {"host": "abc.execute-api.us-east-1.amazonaws.com"},
+		"requestContext": {"stage": "prod", "http": {"method": "GET"}}
+	}`
+
+	_, err := h.Handle(json.RawMessage(event), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/users", path)
+	assert.Equal(t, "https://abc.execute-api.us-east-1.amazonaws.com/prod/login", location)
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	var location string
+
+	h := Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		location = AbsoluteURL(r, "/login?next=%2F")
+	}))
+
+	cases := map[string]string{
+		`{"httpMethod": "GET", "path": "/", "headers": {"Host": "abc.execute-api.us-east-1.amazonaws.com"}, "requestContext": {"stage": "prod"}}`: "https://abc.execute-api.us-east-1.amazonaws.com/prod/login?next=%2F",
+		`{"httpMethod": "GET", "path": "/", "headers": {"Host": "example.com"}, "requestContext": {"stage": "prod"}}`:                             "https://example.com/login?next=%2F",
+		`{"httpMethod": "GET", "path": "/", "headers": {"Host": "internal"}, "requestContext": {"domainName": "example.com"}}`:                    "https://example.com/login?next=%2F",
+
+		// Load balancers append the scheme and port
+		`{"httpMethod": "GET", "path": "/", "headers": {"Host": "example.com", "X-Forwarded-Proto": "http", "X-Forwarded-Port": "8080"}, "requestContext": {"elb": {}}}`:          "http://example.com:8080/login?next=%2F",
+		`{"httpMethod": "GET", "path": "/", "headers": {"Host": "example.com", "X-Forwarded-Proto": "http, https", "X-Forwarded-Port": "1, 443"}, "requestContext": {"elb": {}}}`: "https://example.com/login?next=%2F",
+
+		// Spoofed by the client
+		`{"httpMethod": "GET", "path": "/", "headers": {"Host": "example.com", "X-Forwarded-Host": "evil.com", "X-Forwarded-Proto": "http", "X-Forwarded-Port": "8080"}}`: "https://example.com/login?next=%2F",
+	}
+
+	for event, url := range cases {
+		_, err := h.Handle(json.RawMessage(event), &apex.Context{})
+		assert.NoError(t, err)
+		assert.Equal(t, url, location, event)
+	}
+}
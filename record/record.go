@@ -0,0 +1,309 @@
+// Package record records invocations to a JSON lines sink and replays them
+// through a handler to reproduce failures and catch regressions.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/go-apex"
+)
+
+// Redacted replaces the value of redacted fields.
+const Redacted = "[REDACTED]"
+
+// Frame is a single recorded invocation.
+type Frame struct {
+	Time    time.Time       `json:"time"`
+	Event   json.RawMessage `json:"event"`
+	Context *apex.Context   `json:"context"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Config for recording.
+type Config struct {
+	// Writer receives one JSON frame per line.
+	Writer io.Writer
+
+	// SampleRate is the fraction of invocations recorded, between 0 and 1.
+	// Zero records every invocation.
+	SampleRate float64
+
+	// Redact lists dot separated paths of event, context and value fields
+	// replaced by Redacted, for example "headers.Authorization" or
+	// "clientContext.custom.token". A "*" segment matches any key or array
+	// element, and keys match case-insensitively.
+	Redact []string
+}
+
+// recorder implements apex.Handler.
+type recorder struct {
+	Handler apex.Handler
+	Config
+	mu sync.Mutex
+}
+
+// New returns a handler recording the invocations of h.
+func New(h apex.Handler, c Config) apex.Handler {
+	return &recorder{Handler: h, Config: c}
+}
+
+// Handle implements apex.Handler.
+func (r *recorder) Handle(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	v, err := r.Handler.Handle(event, ctx)
+
+	if r.SampleRate > 0 && rand.Float64() >= r.SampleRate {
+		return v, err
+	}
+
+	f := &Frame{
+		Time:    time.Now().UTC(),
+		Event:   redact(event, r.Redact),
+		Context: redactContext(ctx, r.Redact),
+	}
+
+	if err != nil {
+		f.Error = err.Error()
+	}
+
+	if v != nil {
+		b, merr := json.Marshal(v)
+		if merr != nil {
+			log.Printf("error recording value: %s", merr)
+		} else {
+			f.Value = redact(b, r.Redact)
+		}
+	}
+
+	b, merr := json.Marshal(f)
+	if merr != nil {
+		log.Printf("error recording frame: %s", merr)
+		return v, err
+	}
+
+	r.mu.Lock()
+	_, werr := r.Writer.Write(append(b, '\n'))
+	r.mu.Unlock()
+
+	if werr != nil {
+		log.Printf("error writing frame: %s", werr)
+	}
+
+	return v, err
+}
+
+// Diff is a replayed frame whose output differs from the recording.
+type Diff struct {
+	// Line of the frame in the recording.
+	Line int
+
+	// Frame is the recorded frame.
+	Frame *Frame
+
+	// Value and Error are the replayed output.
+	Value json.RawMessage
+	Error string
+}
+
+// String returns a description of the difference.
+func (d *Diff) String() string {
+	var s []string
+
+	if d.Error != d.Frame.Error {
+		s = append(s, fmt.Sprintf("error %q, recorded %q", d.Error, d.Frame.Error))
+	}
+
+	if !equal(d.Value, d.Frame.Value) {
+		s = append(s, fmt.Sprintf("value %s, recorded %s", orNull(d.Value), orNull(d.Frame.Value)))
+	}
+
+	return fmt.Sprintf("line %d: %s", d.Line, strings.Join(s, "; "))
+}
+
+// Report of a replay.
+type Report struct {
+	Frames int
+	Diffs  []*Diff
+}
+
+// Replay feeds each frame read from r through h and reports frames whose
+// output differs from the recording. The redact paths used for recording
+// are applied to replayed values before they are compared.
+func Replay(r io.Reader, h apex.Handler, redactPaths []string) (*Report, error) {
+	report := &Report{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 16<<20)
+
+	for line := 1; s.Scan(); line++ {
+		if len(strings.TrimSpace(s.Text())) == 0 {
+			continue
+		}
+
+		var f Frame
+		if err := json.Unmarshal(s.Bytes(), &f); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		report.Frames++
+
+		d := &Diff{Line: line, Frame: &f}
+
+		v, err := h.Handle(f.Event, f.Context)
+		if err != nil {
+			d.Error = err.Error()
+		}
+
+		if v != nil {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: marshalling value: %s", line, err)
+			}
+			d.Value = redact(b, redactPaths)
+		}
+
+		if d.Error != f.Error || !equal(d.Value, f.Value) {
+			report.Diffs = append(report.Diffs, d)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Handle replays the file named by the APEX_REPLAY environment variable
+// through h, printing differences to stderr and exiting non-zero when
+// any frame differs. APEX_REPLAY_REDACT may hold comma separated redact
+// paths. Without APEX_REPLAY it behaves like apex.Handle.
+func Handle(h apex.Handler) {
+	path := os.Getenv("APEX_REPLAY")
+	if path == "" {
+		apex.Handle(h)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("error opening replay: %s", err)
+	}
+	defer f.Close()
+
+	var paths []string
+	if s := os.Getenv("APEX_REPLAY_REDACT"); s != "" {
+		paths = strings.Split(s, ",")
+	}
+
+	report, err := Replay(f, h, paths)
+	if err != nil {
+		log.Fatalf("error replaying: %s", err)
+	}
+
+	for _, d := range report.Diffs {
+		log.Print(d)
+	}
+
+	log.Printf("replayed %d frames, %d differ", report.Frames, len(report.Diffs))
+
+	if len(report.Diffs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// equal reports whether a and b hold the same JSON value.
+func equal(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	json.Unmarshal(orNull(a), &va)
+	json.Unmarshal(orNull(b), &vb)
+	return reflect.DeepEqual(va, vb)
+}
+
+// orNull returns JSON null for empty values.
+func orNull(b json.RawMessage) json.RawMessage {
+	if len(b) == 0 {
+		return json.RawMessage("null")
+	}
+	return b
+}
+
+// redact replaces the fields at paths in the JSON document b.
+func redact(b json.RawMessage, paths []string) json.RawMessage {
+	if len(paths) == 0 || len(b) == 0 {
+		return b
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+
+	for _, p := range paths {
+		v = redactPath(v, strings.Split(p, "."))
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return b
+	}
+
+	return out
+}
+
+// redactContext returns a copy of ctx with the fields at paths replaced. The
+// context is omitted when a path matches a field which cannot hold Redacted.
+func redactContext(ctx *apex.Context, paths []string) *apex.Context {
+	if ctx == nil || len(paths) == 0 {
+		return ctx
+	}
+
+	b, err := json.Marshal(ctx)
+	if err != nil {
+		log.Printf("error redacting context: %s", err)
+		return nil
+	}
+
+	var c apex.Context
+	if err := json.Unmarshal(redact(b, paths), &c); err != nil {
+		log.Printf("error redacting context: %s", err)
+		return nil
+	}
+
+	return &c
+}
+
+// redactPath replaces the fields of v matching segments.
+func redactPath(v interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return Redacted
+	}
+
+	seg, rest := segments[0], segments[1:]
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if seg == "*" || strings.EqualFold(seg, k) {
+				v[k] = redactPath(child, rest)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if seg == "*" || seg == fmt.Sprint(i) {
+				v[i] = redactPath(child, rest)
+			}
+		}
+	}
+
+	return v
+}
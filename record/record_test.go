@@ -0,0 +1,128 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+type message struct {
+	Value string `json:"value"`
+	Token string `json:"token,omitempty"`
+}
+
+var upper = apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	var m message
+	if err := json.Unmarshal(event, &m); err != nil {
+		return nil, err
+	}
+	if m.Value == "" {
+		return nil, errors.New("missing value")
+	}
+	m.Value = strings.ToUpper(m.Value)
+	return m, nil
+})
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := New(upper, Config{
+		Writer: &buf,
+		Redact: []string{"token", "headers.authorization"},
+	})
+
+	v, err := h.Handle(json.RawMessage(`{"value":"hello","token":"secret","headers":{"Authorization":"Bearer x"}}`), &apex.Context{RequestID: "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, message{"HELLO", "secret"}, v)
+
+	_, err = h.Handle(json.RawMessage(`{}`), &apex.Context{RequestID: "2"})
+	assert.EqualError(t, err, "missing value")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var f Frame
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &f))
+	assert.Equal(t, "1", f.Context.RequestID)
+	assert.JSONEq(t, `{"value":"hello","token":"[REDACTED]","headers":{"Authorization":"[REDACTED]"}}`, string(f.Event))
+	assert.JSONEq(t, `{"value":"HELLO","token":"[REDACTED]"}`, string(f.Value))
+	assert.Empty(t, f.Error)
+
+	var g Frame
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &g))
+	assert.Equal(t, "missing value", g.Error)
+	assert.Empty(t, g.Value)
+}
+
+func TestRecorder_context(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := New(upper, Config{
+		Writer: &buf,
+		Redact: []string{"clientContext.custom.token", "identity.cognitoIdentityId"},
+	})
+
+	ctx := &apex.Context{
+		RequestID:     "1",
+		ClientContext: json.RawMessage(`{"custom":{"token":"secret","app":"web"}}`),
+		Identity:      apex.Identity{CognitoIdentityID: "us-east-1:abc"},
+	}
+
+	_, err := h.Handle(json.RawMessage(`{"value":"hello"}`), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1:abc", ctx.Identity.CognitoIdentityID)
+
+	var f Frame
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &f))
+	assert.Equal(t, "1", f.Context.RequestID)
+	assert.JSONEq(t, `{"custom":{"token":"[REDACTED]","app":"web"}}`, string(f.Context.ClientContext))
+	assert.Equal(t, Redacted, f.Context.Identity.CognitoIdentityID)
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+func TestRecorder_sampling(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(upper, Config{Writer: &buf, SampleRate: 0.0001})
+
+	for i := 0; i < 10; i++ {
+		h.Handle(json.RawMessage(`{"value":"hello"}`), nil)
+	}
+
+	assert.True(t, strings.Count(buf.String(), "\n") < 10)
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(upper, Config{Writer: &buf, Redact: []string{"token"}})
+
+	h.Handle(json.RawMessage(`{"value":"hello","token":"secret"}`), nil)
+	h.Handle(json.RawMessage(`{"value":"world"}`), nil)
+	h.Handle(json.RawMessage(`{}`), nil)
+
+	report, err := Replay(bytes.NewReader(buf.Bytes()), upper, []string{"token"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Frames)
+	assert.Empty(t, report.Diffs)
+
+	lower := apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+		var m message
+		json.Unmarshal(event, &m)
+		return m, nil
+	})
+
+	report, err = Replay(bytes.NewReader(buf.Bytes()), lower, []string{"token"})
+	assert.NoError(t, err)
+	assert.Len(t, report.Diffs, 3)
+	assert.Equal(t, `line 2: value {"value":"world"}, recorded {"value":"WORLD"}`, report.Diffs[1].String())
+	assert.Equal(t, `line 3: error "", recorded "missing value"; value {"value":""}, recorded null`, report.Diffs[2].String())
+}
+
+func TestReplay_malformed(t *testing.T) {
+	_, err := Replay(strings.NewReader("{}\nnope\n"), upper, nil)
+	assert.EqualError(t, err, "line 2: invalid character 'o' in literal null (expecting 'u')")
+}
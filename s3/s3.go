@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/apex/go-apex"
+	"github.com/apex/go-apex/batch"
 )
 
 // Event represents a S3 event with one or more records.
@@ -52,6 +53,21 @@ type Record struct {
 	}
 }
 
+// Items returns the records as batch items keyed by bucket and object key,
+// so that events for the same object are processed in order.
+func (e *Event) Items() []batch.Item {
+	items := make([]batch.Item, len(e.Records))
+	for i, r := range e.Records {
+		item := batch.Item{Record: r}
+		if r.S3.Bucket != nil && r.S3.Object != nil {
+			item.ID = r.S3.Bucket.Name + "/" + r.S3.Object.Key + "@" + r.S3.Object.Sequencer
+			item.Key = r.S3.Bucket.Name + "/" + r.S3.Object.Key
+		}
+		items[i] = item
+	}
+	return items
+}
+
 // Handler handles S3 events.
 type Handler interface {
 	HandleS3(*Event, *apex.Context) error
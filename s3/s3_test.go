@@ -16,3 +16,33 @@ func Test(t *testing.T) {
 	assert.Nil(t, nil)
 	// TODO: unmarshalling test
 }
+
+func TestEvent_Items(t *testing.T) {
+	cases := []struct {
+		bucket *Bucket
+		object *Object
+		id     string
+		key    string
+	}{
+		{&Bucket{Name: "logs"}, &Object{Key: "a.txt", Sequencer: "01"}, "logs/a.txt@01", "logs/a.txt"},
+		{&Bucket{Name: "logs"}, &Object{Key: "a.txt", Sequencer: "02"}, "logs/a.txt@02", "logs/a.txt"},
+		{&Bucket{Name: "images"}, &Object{Key: "a.txt", Sequencer: "03"}, "images/a.txt@03", "images/a.txt"},
+		{nil, &Object{Key: "a.txt"}, "", ""},
+	}
+
+	var e Event
+	for _, c := range cases {
+		r := &Record{}
+		r.S3.Bucket = c.bucket
+		r.S3.Object = c.object
+		e.Records = append(e.Records, r)
+	}
+
+	items := e.Items()
+	assert.Len(t, items, len(cases))
+	for i, c := range cases {
+		assert.Equal(t, c.id, items[i].ID)
+		assert.Equal(t, c.key, items[i].Key)
+		assert.Equal(t, e.Records[i], items[i].Record)
+	}
+}
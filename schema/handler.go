@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/apex/go-apex"
+)
+
+// Config for validating a handler.
+type Config struct {
+	// Event is the schema of inbound events.
+	Event *Schema
+
+	// Result is the optional schema of values returned by the handler.
+	Result *Schema
+}
+
+// handler implements apex.Handler.
+type handler struct {
+	Handler apex.Handler
+	Config
+}
+
+// New returns a handler validating events before passing them to h, and
+// validating the values it returns. Invalid events are rejected with a
+// *ValidationError without calling h, and invalid results with one whose
+// Result field is set.
+func New(h apex.Handler, c Config) apex.Handler {
+	return &handler{h, c}
+}
+
+// Handle implements apex.Handler.
+func (h *handler) Handle(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	if h.Event != nil {
+		if err := h.Event.Validate(event); err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := h.Handler.Handle(event, ctx)
+	if err != nil || h.Result == nil {
+		return v, err
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling result: %s", err)
+	}
+
+	if err := h.Result.Validate(b); err != nil {
+		if e, ok := err.(*ValidationError); ok {
+			e.Result = true
+		}
+		return nil, err
+	}
+
+	return v, nil
+}
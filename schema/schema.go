@@ -0,0 +1,454 @@
+// Package schema validates events and results against JSON Schema documents.
+//
+// The following keywords are supported: type, enum, const, properties,
+// required, additionalProperties, items, minItems, maxItems, uniqueItems,
+// minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, multipleOf, allOf, anyOf, oneOf and not. Other keywords,
+// such as $ref and format, are ignored.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema document.
+type Schema struct {
+	Type                 types              `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Const                *interface{}       `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MultipleOf           *float64           `json:"multipleOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+
+	// reject is set for the boolean schema false.
+	reject  bool
+	pattern *regexp.Regexp
+}
+
+// types is a list of JSON types, which may be given as a single string.
+type types []string
+
+// UnmarshalJSON accepts a single type or a list of types.
+func (t *types) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = types{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+
+	*t = l
+	return nil
+}
+
+// schemaAlias avoids recursion in UnmarshalJSON.
+type schemaAlias Schema
+
+// UnmarshalJSON accepts boolean schemas in addition to objects.
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	var accept bool
+	if err := json.Unmarshal(b, &accept); err == nil {
+		*s = Schema{reject: !accept}
+		return nil
+	}
+
+	if err := json.Unmarshal(b, (*schemaAlias)(s)); err != nil {
+		return err
+	}
+
+	if s.Pattern != "" {
+		r, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = r
+	}
+
+	return nil
+}
+
+// Parse returns the schema defined by the JSON document b.
+func Parse(b []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("parsing schema: %s", err)
+	}
+	return &s, nil
+}
+
+// MustParse is like Parse but panics on error.
+func MustParse(s string) *Schema {
+	v, err := Parse([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Violation is a single failed constraint.
+type Violation struct {
+	// Path is a JSON pointer to the offending value, "/" for the document.
+	Path string `json:"path"`
+
+	// Message describes the failed constraint.
+	Message string `json:"message"`
+}
+
+// String returns the string representation.
+func (v *Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError lists every violation of a document.
+type ValidationError struct {
+	Violations []*Violation `json:"violations"`
+
+	// Result is set when the document is the result of a handler rather
+	// than its event.
+	Result bool `json:"result,omitempty"`
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	var s []string
+	for _, v := range e.Violations {
+		s = append(s, v.String())
+	}
+
+	msg := "validation failed: " + strings.Join(s, "; ")
+	if e.Result {
+		msg = "invalid result: " + msg
+	}
+
+	return msg
+}
+
+// Validate the JSON document b, returning a *ValidationError when it
+// violates the schema, or the decoding error when b is not valid JSON.
+func (s *Schema) Validate(b []byte) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return err
+	}
+
+	var violations []*Violation
+	s.validate(v, "", &violations)
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Violations: violations}
+}
+
+// validate appends the violations of v at path.
+func (s *Schema) validate(v interface{}, path string, out *[]*Violation) {
+	fail := func(format string, args ...interface{}) {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		*out = append(*out, &Violation{p, fmt.Sprintf(format, args...)})
+	}
+
+	if s.reject {
+		fail("is not allowed")
+		return
+	}
+
+	if len(s.Type) > 0 && !s.Type.match(v) {
+		fail("must be of type %s, got %s", strings.Join(s.Type, " or "), typeOf(v))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, e := range s.Enum {
+			if equal(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("must be one of %s", marshal(s.Enum))
+		}
+	}
+
+	if s.Const != nil && !equal(v, *s.Const) {
+		fail("must be %s", marshal(*s.Const))
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, path, out)
+	case []interface{}:
+		s.validateArray(v, path, out, fail)
+	case string:
+		s.validateString(v, fail)
+	case json.Number:
+		s.validateNumber(v, fail)
+	}
+
+	for _, sub := range s.AllOf {
+		sub.validate(v, path, out)
+	}
+
+	if len(s.AnyOf) > 0 && matches(s.AnyOf, v) == 0 {
+		fail("must match at least one schema in anyOf")
+	}
+
+	if len(s.OneOf) > 0 {
+		if n := matches(s.OneOf, v); n != 1 {
+			fail("must match exactly one schema in oneOf, matched %d", n)
+		}
+	}
+
+	if s.Not != nil && matches([]*Schema{s.Not}, v) == 1 {
+		fail("must not match schema in not")
+	}
+}
+
+// validateObject checks the object keywords.
+func (s *Schema) validateObject(v map[string]interface{}, path string, out *[]*Violation) {
+	for _, name := range s.Required {
+		if _, ok := v[name]; !ok {
+			*out = append(*out, &Violation{path + "/" + escape(name), "is required"})
+		}
+	}
+
+	var names []string
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := path + "/" + escape(name)
+
+		if sub, ok := s.Properties[name]; ok {
+			sub.validate(v[name], p, out)
+			continue
+		}
+
+		if s.AdditionalProperties != nil {
+			if s.AdditionalProperties.reject {
+				*out = append(*out, &Violation{p, "is not an allowed property"})
+				continue
+			}
+			s.AdditionalProperties.validate(v[name], p, out)
+		}
+	}
+}
+
+// validateArray checks the array keywords.
+func (s *Schema) validateArray(v []interface{}, path string, out *[]*Violation, fail func(string, ...interface{})) {
+	if s.MinItems != nil && len(v) < *s.MinItems {
+		fail("must have at least %d items", *s.MinItems)
+	}
+
+	if s.MaxItems != nil && len(v) > *s.MaxItems {
+		fail("must have at most %d items", *s.MaxItems)
+	}
+
+	if s.UniqueItems {
+	unique:
+		for i := range v {
+			for j := 0; j < i; j++ {
+				if equal(v[i], v[j]) {
+					fail("must have unique items, %d and %d are equal", j, i)
+					break unique
+				}
+			}
+		}
+	}
+
+	if s.Items != nil {
+		for i, item := range v {
+			s.Items.validate(item, fmt.Sprintf("%s/%d", path, i), out)
+		}
+	}
+}
+
+// validateString checks the string keywords.
+func (s *Schema) validateString(v string, fail func(string, ...interface{})) {
+	n := utf8.RuneCountInString(v)
+
+	if s.MinLength != nil && n < *s.MinLength {
+		fail("must be at least %d characters long", *s.MinLength)
+	}
+
+	if s.MaxLength != nil && n > *s.MaxLength {
+		fail("must be at most %d characters long", *s.MaxLength)
+	}
+
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		fail("must match pattern %q", s.Pattern)
+	}
+}
+
+// validateNumber checks the numeric keywords.
+func (s *Schema) validateNumber(n json.Number, fail func(string, ...interface{})) {
+	v, _ := n.Float64()
+
+	if s.Minimum != nil && v < *s.Minimum {
+		fail("must be >= %v", *s.Minimum)
+	}
+
+	if s.Maximum != nil && v > *s.Maximum {
+		fail("must be <= %v", *s.Maximum)
+	}
+
+	if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+		fail("must be > %v", *s.ExclusiveMinimum)
+	}
+
+	if s.ExclusiveMaximum != nil && v >= *s.ExclusiveMaximum {
+		fail("must be < %v", *s.ExclusiveMaximum)
+	}
+
+	if s.MultipleOf != nil && *s.MultipleOf != 0 {
+		if !isMultiple(n, *s.MultipleOf) {
+			fail("must be a multiple of %v", *s.MultipleOf)
+		}
+	}
+}
+
+// isMultiple reports whether n is a multiple of m. The decimal values are
+// compared exactly, as binary floating point fails for fractions such as
+// 0.07 and 0.01.
+func isMultiple(n json.Number, m float64) bool {
+	v, ok := new(big.Rat).SetString(n.String())
+	if !ok {
+		return false
+	}
+
+	d, ok := new(big.Rat).SetString(strconv.FormatFloat(m, 'g', -1, 64))
+	if !ok {
+		return false
+	}
+
+	return v.Quo(v, d).IsInt()
+}
+
+// match reports whether v has one of the types.
+func (t types) match(v interface{}) bool {
+	actual := typeOf(v)
+
+	for _, name := range t {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeOf returns the JSON type name of v.
+func typeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// matches returns the number of schemas v is valid against.
+func matches(schemas []*Schema, v interface{}) int {
+	n := 0
+	for _, s := range schemas {
+		var out []*Violation
+		s.validate(v, "", &out)
+		if len(out) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// equal reports whether a and b are the same JSON value.
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// normalize converts numbers to float64 so that they compare by value.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalize(e)
+		}
+		return l
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// escape returns name escaped as a JSON pointer token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// marshal returns the JSON representation of v.
+func marshal(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
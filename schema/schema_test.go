@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+var user = MustParse(`{
+	"type": "object",
+	"required": ["name", "email", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": { "type": "string", "minLength": 1, "maxLength": 10 },
+		"email": { "type": "string", "pattern": "^[^@]+@[^@]+$" },
+		"age": { "type": "integer", "minimum": 0, "exclusiveMaximum": 150 },
+		"role": { "enum": ["admin", "user"] },
+		"tags": {
+			"type": "array",
+			"maxItems": 2,
+			"uniqueItems": true,
+			"items": { "type": "string" }
+		},
+		"id": { "type": ["string", "integer"] }
+	}
+}`)
+
+func TestSchema_Validate(t *testing.T) {
+	assert.NoError(t, user.Validate([]byte(`{"name":"tj","email":"tj@apex.sh","age":30,"role":"admin","tags":["a","b"],"id":5}`)))
+
+	err := user.Validate([]byte(`{"name":"","email":"nope","age":30.5,"role":"root","tags":["a","a",1],"id":true,"extra":1}`))
+	e, ok := err.(*ValidationError)
+	assert.True(t, ok, "error is *ValidationError")
+	assert.Equal(t, []*Violation{
+		{"/age", "must be of type integer, got number"},
+		{"/email", `must match pattern "^[^@]+@[^@]+$"`},
+		{"/extra", "is not an allowed property"},
+		{"/id", "must be of type string or integer, got boolean"},
+		{"/name", "must be at least 1 characters long"},
+		{"/role", `must be one of ["admin","user"]`},
+		{"/tags", "must have at most 2 items"},
+		{"/tags", "must have unique items, 0 and 1 are equal"},
+		{"/tags/2", "must be of type string, got integer"},
+	}, e.Violations)
+
+	err = user.Validate([]byte(`{"age":-1}`))
+	assert.EqualError(t, err, "validation failed: /name: is required; /email: is required; /age: must be >= 0")
+
+	err = user.Validate([]byte(`[]`))
+	assert.EqualError(t, err, "validation failed: /: must be of type object, got array")
+
+	err = user.Validate([]byte(`{`))
+	assert.EqualError(t, err, "unexpected EOF")
+}
+
+func TestSchema_combinators(t *testing.T) {
+	s := MustParse(`{
+		"oneOf": [
+			{ "type": "integer", "multipleOf": 3 },
+			{ "type": "integer", "multipleOf": 5 }
+		],
+		"not": { "const": 0 }
+	}`)
+
+	assert.NoError(t, s.Validate([]byte(`9`)))
+	assert.NoError(t, s.Validate([]byte(`10`)))
+	assert.EqualError(t, s.Validate([]byte(`15`)), "validation failed: /: must match exactly one schema in oneOf, matched 2")
+	assert.EqualError(t, s.Validate([]byte(`7`)), "validation failed: /: must match exactly one schema in oneOf, matched 0")
+	assert.EqualError(t, s.Validate([]byte(`0`)), "validation failed: /: must match exactly one schema in oneOf, matched 2; /: must not match schema in not")
+}
+
+func TestSchema_multipleOf(t *testing.T) {
+	s := MustParse(`{ "multipleOf": 0.01 }`)
+
+	assert.NoError(t, s.Validate([]byte(`0.07`)))
+	assert.NoError(t, s.Validate([]byte(`19.99`)))
+	assert.NoError(t, s.Validate([]byte(`-1e2`)))
+	assert.EqualError(t, s.Validate([]byte(`0.075`)), "validation failed: /: must be a multiple of 0.01")
+
+	s = MustParse(`{ "multipleOf": 0.1 }`)
+	assert.NoError(t, s.Validate([]byte(`0.3`)))
+	assert.EqualError(t, s.Validate([]byte(`0.35`)), "validation failed: /: must be a multiple of 0.1")
+}
+
+func TestParse(t *testing.T) {
+	_, err := Parse([]byte(`{"pattern": "("}`))
+	assert.EqualError(t, err, "parsing schema: error parsing regexp: missing closing ): `(`")
+}
+
+func TestHandler(t *testing.T) {
+	calls := 0
+	next := apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+		calls++
+		return map[string]interface{}{"ok": calls}, nil
+	})
+
+	h := New(next, Config{
+		Event:  user,
+		Result: MustParse(`{"properties": {"ok": {"maximum": 1}}}`),
+	})
+
+	_, err := h.Handle(json.RawMessage(`{"name":"tj"}`), nil)
+	assert.EqualError(t, err, "validation failed: /email: is required; /age: is required")
+	assert.Equal(t, 0, calls)
+
+	v, err := h.Handle(json.RawMessage(`{"name":"tj","email":"tj@apex.sh","age":30}`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"ok": 1}, v)
+
+	_, err = h.Handle(json.RawMessage(`{"name":"tj","email":"tj@apex.sh","age":30}`), nil)
+	e, ok := err.(*ValidationError)
+	assert.True(t, ok, "error is *ValidationError")
+	assert.True(t, e.Result)
+	assert.Equal(t, "/ok", e.Violations[0].Path)
+	assert.EqualError(t, err, "invalid result: validation failed: /ok: must be <= 1")
+}
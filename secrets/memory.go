@@ -0,0 +1,71 @@
+package secrets
+
+import "sync"
+
+// Memory is an in-memory Provider and Decrypter for offline testing.
+// Decrypt looks up the ciphertext as a name.
+type Memory struct {
+	mu     sync.Mutex
+	values map[string]string
+	calls  map[string]int
+}
+
+// NewMemory returns a Memory holding values.
+func NewMemory(values map[string]string) *Memory {
+	m := &Memory{
+		values: make(map[string]string),
+		calls:  make(map[string]int),
+	}
+
+	for k, v := range values {
+		m.values[k] = v
+	}
+
+	return m
+}
+
+// Set the value of name.
+func (m *Memory) Set(name, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name] = value
+}
+
+// Delete name.
+func (m *Memory) Delete(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, name)
+}
+
+// Calls returns the number of times name was fetched.
+func (m *Memory) Calls(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[name]
+}
+
+// Get implements Provider.
+func (m *Memory) Get(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls[name]++
+
+	v, ok := m.values[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return v, nil
+}
+
+// Decrypt implements Decrypter.
+func (m *Memory) Decrypt(ciphertext []byte) ([]byte, error) {
+	v, err := m.Get(string(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(v), nil
+}
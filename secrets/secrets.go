@@ -0,0 +1,296 @@
+// Package secrets resolves SSM parameters, Secrets Manager values and KMS
+// encrypted values, caching them between invocations.
+//
+// References take the following forms:
+//
+//	ssm:/path/to/parameter
+//	secretsmanager:name
+//	secretsmanager:name#key
+//	kms:<base64 ciphertext>
+//
+// The #key suffix selects a field of a JSON secret.
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/go-apex"
+)
+
+// Reference prefixes.
+const (
+	SSM            = "ssm:"
+	SecretsManager = "secretsmanager:"
+	KMS            = "kms:"
+)
+
+// ErrNotFound is returned by providers for unknown names.
+var ErrNotFound = errors.New("not found")
+
+// Provider fetches values by name.
+type Provider interface {
+	Get(name string) (string, error)
+}
+
+// ProviderFunc implements Provider.
+type ProviderFunc func(name string) (string, error)
+
+// Get implements Provider.
+func (f ProviderFunc) Get(name string) (string, error) {
+	return f(name)
+}
+
+// Decrypter decrypts ciphertexts.
+type Decrypter interface {
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// DecrypterFunc implements Decrypter.
+type DecrypterFunc func(ciphertext []byte) ([]byte, error)
+
+// Decrypt implements Decrypter.
+func (f DecrypterFunc) Decrypt(ciphertext []byte) ([]byte, error) {
+	return f(ciphertext)
+}
+
+// IsReference reports whether s is a reference.
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, SSM) ||
+		strings.HasPrefix(s, SecretsManager) ||
+		strings.HasPrefix(s, KMS)
+}
+
+// entry is a cached value.
+type entry struct {
+	value   string
+	fetched time.Time
+}
+
+// Resolver resolves and caches references.
+type Resolver struct {
+	// SSM fetches parameters for ssm: references.
+	SSM Provider
+
+	// SecretsManager fetches secrets for secretsmanager: references.
+	SecretsManager Provider
+
+	// KMS decrypts kms: references.
+	KMS Decrypter
+
+	// TTL after which values are fetched again by Refresh. Zero caches
+	// values forever.
+	TTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*entry
+	env   map[string]string
+	now   func() time.Time
+}
+
+// Resolve returns the value of ref, using the cache when possible.
+func (r *Resolver) Resolve(ref string) (string, error) {
+	source, key := split(ref)
+
+	r.mu.Lock()
+	e, ok := r.cache[source]
+	r.mu.Unlock()
+
+	if !ok {
+		var err error
+		if e, err = r.fetch(source); err != nil {
+			return "", err
+		}
+	}
+
+	return field(e.value, key)
+}
+
+// Env resolves every environment variable holding a reference and replaces
+// its value. The variables are updated again by Refresh.
+func (r *Resolver) Env() error {
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 || !IsReference(kv[i+1:]) {
+			continue
+		}
+
+		name, ref := kv[:i], kv[i+1:]
+
+		v, err := r.Resolve(ref)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %s", name, err)
+		}
+
+		r.mu.Lock()
+		if r.env == nil {
+			r.env = make(map[string]string)
+		}
+		r.env[name] = ref
+		r.mu.Unlock()
+
+		os.Setenv(name, v)
+	}
+
+	return nil
+}
+
+// Refresh fetches expired values again and updates the environment
+// variables resolved by Env. Values which fail to refresh are kept.
+func (r *Resolver) Refresh() error {
+	if r.TTL == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	var expired []string
+	for source, e := range r.cache {
+		if r.clock().Sub(e.fetched) >= r.TTL {
+			expired = append(expired, source)
+		}
+	}
+	r.mu.Unlock()
+
+	var errs []string
+	for _, source := range expired {
+		if _, err := r.fetch(source); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	r.mu.Lock()
+	env := make(map[string]string, len(r.env))
+	for name, ref := range r.env {
+		env[name] = ref
+	}
+	r.mu.Unlock()
+
+	for name, ref := range env {
+		v, err := r.Resolve(ref)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("resolving %s: %s", name, err))
+			continue
+		}
+		os.Setenv(name, v)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// fetch retrieves source from its provider and caches it.
+func (r *Resolver) fetch(source string) (*entry, error) {
+	v, err := r.get(source)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %s", redact(source), err)
+	}
+
+	e := &entry{value: v, fetched: r.clock()}
+
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]*entry)
+	}
+	r.cache[source] = e
+	r.mu.Unlock()
+
+	return e, nil
+}
+
+// get retrieves source from its provider.
+func (r *Resolver) get(source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, SSM):
+		if r.SSM == nil {
+			return "", errors.New("no SSM provider")
+		}
+		return r.SSM.Get(strings.TrimPrefix(source, SSM))
+	case strings.HasPrefix(source, SecretsManager):
+		if r.SecretsManager == nil {
+			return "", errors.New("no Secrets Manager provider")
+		}
+		return r.SecretsManager.Get(strings.TrimPrefix(source, SecretsManager))
+	case strings.HasPrefix(source, KMS):
+		if r.KMS == nil {
+			return "", errors.New("no KMS decrypter")
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(source, KMS))
+		if err != nil {
+			return "", err
+		}
+		b, err = r.KMS.Decrypt(b)
+		return string(b), err
+	default:
+		return "", errors.New("unknown reference type")
+	}
+}
+
+// clock returns the current time.
+func (r *Resolver) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// Handler returns a handler refreshing r before passing each event to h.
+func Handler(h apex.Handler, r *Resolver) apex.Handler {
+	return apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+		if err := r.Refresh(); err != nil {
+			log.Printf("error refreshing secrets: %s", err)
+		}
+		return h.Handle(event, ctx)
+	})
+}
+
+// split separates the source of ref from its optional #key.
+func split(ref string) (source, key string) {
+	if strings.HasPrefix(ref, SecretsManager) {
+		if i := strings.LastIndex(ref, "#"); i >= 0 {
+			return ref[:i], ref[i+1:]
+		}
+	}
+	return ref, ""
+}
+
+// field returns the key field of the JSON object v, or v when key is empty.
+func field(v, key string) (string, error) {
+	if key == "" {
+		return v, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(v), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %s", err)
+	}
+
+	f, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+
+	if s, ok := f.(string); ok {
+		return s, nil
+	}
+
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+// redact hides ciphertexts from error messages.
+func redact(source string) string {
+	if strings.HasPrefix(source, KMS) {
+		return KMS + "..."
+	}
+	return source
+}
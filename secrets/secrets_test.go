@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	ssm := NewMemory(map[string]string{"/app/db/host": "db.local"})
+	sm := NewMemory(map[string]string{"db": `{"user":"admin","port":5432}`})
+	kms := NewMemory(map[string]string{"ciphertext": "plaintext"})
+
+	r := &Resolver{SSM: ssm, SecretsManager: sm, KMS: kms}
+
+	v, err := r.Resolve("ssm:/app/db/host")
+	assert.NoError(t, err)
+	assert.Equal(t, "db.local", v)
+
+	v, err = r.Resolve("secretsmanager:db#user")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+
+	v, err = r.Resolve("secretsmanager:db#port")
+	assert.NoError(t, err)
+	assert.Equal(t, "5432", v)
+
+	v, err = r.Resolve("secretsmanager:db")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"user":"admin","port":5432}`, v)
+	assert.Equal(t, 1, sm.Calls("db"))
+
+	v, err = r.Resolve("kms:" + base64.StdEncoding.EncodeToString([]byte("ciphertext")))
+	assert.NoError(t, err)
+	assert.Equal(t, "plaintext", v)
+
+	_, err = r.Resolve("secretsmanager:db#missing")
+	assert.EqualError(t, err, `secret has no key "missing"`)
+
+	_, err = r.Resolve("ssm:/missing")
+	assert.EqualError(t, err, "fetching ssm:/missing: not found")
+
+	_, err = (&Resolver{}).Resolve("ssm:/app/db/host")
+	assert.EqualError(t, err, "fetching ssm:/app/db/host: no SSM provider")
+}
+
+func TestResolver_Refresh(t *testing.T) {
+	now := time.Unix(0, 0)
+	ssm := NewMemory(map[string]string{"/token": "one"})
+	r := &Resolver{SSM: ssm, TTL: time.Minute, now: func() time.Time { return now }}
+
+	os.Setenv("APEX_SECRETS_TEST", "ssm:/token")
+	defer os.Unsetenv("APEX_SECRETS_TEST")
+
+	assert.NoError(t, r.Env())
+	assert.Equal(t, "one", os.Getenv("APEX_SECRETS_TEST"))
+
+	ssm.Set("/token", "two")
+	assert.NoError(t, r.Refresh())
+	assert.Equal(t, "one", os.Getenv("APEX_SECRETS_TEST"))
+	assert.Equal(t, 1, ssm.Calls("/token"))
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, r.Refresh())
+	assert.Equal(t, "two", os.Getenv("APEX_SECRETS_TEST"))
+
+	now = now.Add(time.Minute)
+	ssm.Delete("/token")
+	assert.EqualError(t, r.Refresh(), "fetching ssm:/token: not found")
+	assert.Equal(t, "two", os.Getenv("APEX_SECRETS_TEST"))
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Unix(0, 0)
+	ssm := NewMemory(map[string]string{"/token": "one"})
+	r := &Resolver{SSM: ssm, TTL: time.Minute, now: func() time.Time { return now }}
+
+	h := Handler(apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+		return r.Resolve("ssm:/token")
+	}), r)
+
+	v, _ := h.Handle(nil, nil)
+	assert.Equal(t, "one", v)
+
+	ssm.Set("/token", "two")
+	now = now.Add(time.Hour)
+
+	v, _ = h.Handle(nil, nil)
+	assert.Equal(t, "two", v)
+}
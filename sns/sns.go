@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/apex/go-apex"
+	"github.com/apex/go-apex/batch"
 )
 
 // Event represents a SNS event. It is safe to assume a single
@@ -33,6 +34,18 @@ type Record struct {
 	} `json:"Sns"`
 }
 
+// Items returns the records as unordered batch items.
+func (e *Event) Items() []batch.Item {
+	items := make([]batch.Item, len(e.Records))
+	for i, r := range e.Records {
+		items[i] = batch.Item{
+			ID:     r.SNS.MessageID,
+			Record: r,
+		}
+	}
+	return items
+}
+
 // Handler handles SNS events.
 type Handler interface {
 	HandleSNS(*Event, *apex.Context) error
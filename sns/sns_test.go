@@ -16,3 +16,22 @@ func Test(t *testing.T) {
 	assert.Nil(t, nil)
 	// TODO: unmarshalling test
 }
+
+func TestEvent_Items(t *testing.T) {
+	cases := []string{"95df01b4", "da41e39f"}
+
+	var e Event
+	for _, id := range cases {
+		r := &Record{}
+		r.SNS.MessageID = id
+		e.Records = append(e.Records, r)
+	}
+
+	items := e.Items()
+	assert.Len(t, items, len(cases))
+	for i, id := range cases {
+		assert.Equal(t, id, items[i].ID)
+		assert.Equal(t, "", items[i].Key)
+		assert.Equal(t, e.Records[i], items[i].Record)
+	}
+}
@@ -0,0 +1,232 @@
+// Package warmup answers keep-alive pings without invoking the handler.
+package warmup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/go-apex"
+	"github.com/apex/go-apex/cloudwatch"
+)
+
+// DefaultMarker is the payload field identifying warm-up pings.
+const DefaultMarker = "warmer"
+
+// DefaultDelay is the time a fanned out warm-up invocation is kept busy.
+const DefaultDelay = 75 * time.Millisecond
+
+// DefaultMaxConcurrency is the default limit of containers kept warm by a
+// single ping.
+const DefaultMaxConcurrency = 50
+
+// containerID identifies this container.
+var containerID = newID()
+
+// invoked is set once the container handled its first invocation.
+var invoked int32
+
+// Invoker invokes a Lambda function synchronously.
+type Invoker interface {
+	Invoke(function string, payload []byte) ([]byte, error)
+}
+
+// InvokerFunc implements Invoker.
+type InvokerFunc func(function string, payload []byte) ([]byte, error)
+
+// Invoke implements Invoker.
+func (f InvokerFunc) Invoke(function string, payload []byte) ([]byte, error) {
+	return f(function, payload)
+}
+
+// Config for detecting warm-up pings.
+type Config struct {
+	// Marker is the top-level boolean field of a warm-up payload such as
+	// {"warmer":true}. Defaults to DefaultMarker.
+	Marker string
+
+	// RuleARNs are CloudWatch Events rule ARNs whose scheduled events are
+	// treated as warm-up pings.
+	RuleARNs []string
+
+	// Invoker is used to fan out when a payload such as
+	// {"warmer":true,"concurrency":5} asks for more than one container.
+	Invoker Invoker
+
+	// Delay keeps fanned out invocations busy so they land on distinct
+	// containers. Defaults to DefaultDelay.
+	Delay time.Duration
+
+	// MaxConcurrency limits the concurrency requested by a ping, bounding
+	// the number of concurrent invocations. Defaults to
+	// DefaultMaxConcurrency.
+	MaxConcurrency int
+}
+
+// Response is returned for warm-up pings.
+type Response struct {
+	Warm        bool        `json:"warm"`
+	ContainerID string      `json:"containerId"`
+	ColdStart   bool        `json:"coldStart"`
+	Containers  []*Response `json:"containers,omitempty"`
+}
+
+// handler implements apex.Handler.
+type handler struct {
+	Handler apex.Handler
+	Config
+}
+
+// New returns a handler answering warm-up pings and passing any other
+// event to h.
+func New(h apex.Handler, c Config) apex.Handler {
+	if c.Marker == "" {
+		c.Marker = DefaultMarker
+	}
+
+	if c.Delay == 0 {
+		c.Delay = DefaultDelay
+	}
+
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = DefaultMaxConcurrency
+	}
+
+	return &handler{h, c}
+}
+
+// Handle implements apex.Handler.
+func (h *handler) Handle(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	cold := atomic.CompareAndSwapInt32(&invoked, 0, 1)
+
+	p, ok := h.detect(event)
+	if !ok {
+		return h.Handler.Handle(event, ctx)
+	}
+
+	res := &Response{
+		Warm:        true,
+		ContainerID: containerID,
+		ColdStart:   cold,
+	}
+
+	switch {
+	case p.Fanout:
+		time.Sleep(h.Delay)
+	case p.Concurrency > 1 && h.Invoker != nil && ctx != nil:
+		n := p.Concurrency
+		if n > h.MaxConcurrency {
+			log.Printf("warm-up concurrency %d limited to %d", n, h.MaxConcurrency)
+			n = h.MaxConcurrency
+		}
+		res.Containers = h.fanout(ctx, n-1)
+	}
+
+	return res, nil
+}
+
+// ping is the payload of a warm-up ping.
+type ping struct {
+	// Concurrency is the number of containers to keep warm.
+	Concurrency int `json:"concurrency"`
+
+	// Fanout is set on invocations made by fanout.
+	Fanout bool `json:"fanout"`
+}
+
+// detect reports whether event is a warm-up ping.
+func (h *handler) detect(event json.RawMessage) (*ping, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(event, &fields); err != nil {
+		return nil, false
+	}
+
+	var marker bool
+	if err := json.Unmarshal(fields[h.Marker], &marker); err == nil && marker {
+		var p ping
+		json.Unmarshal(event, &p)
+		return &p, true
+	}
+
+	if len(h.RuleARNs) == 0 {
+		return nil, false
+	}
+
+	var e cloudwatch.Event
+	if err := json.Unmarshal(event, &e); err != nil {
+		return nil, false
+	}
+
+	if e.Source != "aws.events" || e.DetailType != "Scheduled Event" {
+		return nil, false
+	}
+
+	for _, r := range e.Resources {
+		for _, arn := range h.RuleARNs {
+			if r == arn {
+				return &ping{}, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// fanout invokes the function n times concurrently and collects the responses.
+func (h *handler) fanout(ctx *apex.Context, n int) []*Response {
+	function := ctx.InvokedFunctionARN
+	if function == "" {
+		function = ctx.FunctionName
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		h.Marker: true,
+		"fanout": true,
+	})
+
+	var wg sync.WaitGroup
+	res := make([]*Response, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			b, err := h.Invoker.Invoke(function, payload)
+			if err != nil {
+				log.Printf("error invoking warm-up %d of %d: %s", i+1, n, err)
+				return
+			}
+
+			var r Response
+			if err := json.Unmarshal(b, &r); err != nil {
+				log.Printf("error decoding warm-up response: %s", err)
+				return
+			}
+
+			res[i] = &r
+		}(i)
+	}
+
+	wg.Wait()
+
+	var containers []*Response
+	for _, r := range res {
+		if r != nil {
+			containers = append(containers, r)
+		}
+	}
+
+	return containers
+}
+
+// newID returns a random container identifier.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,110 @@
+package warmup
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/stretchr/testify/assert"
+)
+
+var next = apex.HandlerFunc(func(event json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	return "handled", nil
+})
+
+func TestHandler_marker(t *testing.T) {
+	h := New(next, Config{Delay: 1})
+
+	v, err := h.Handle(json.RawMessage(`{"warmer":true}`), &apex.Context{})
+	assert.NoError(t, err)
+
+	res := v.(*Response)
+	assert.True(t, res.Warm)
+	assert.Equal(t, containerID, res.ContainerID)
+	assert.Empty(t, res.Containers)
+
+	v, err = h.Handle(json.RawMessage(`{"warmer":false}`), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "handled", v)
+
+	v, err = h.Handle(json.RawMessage(`[1,2]`), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, "handled", v)
+}
+
+func TestHandler_coldStart(t *testing.T) {
+	atomic.StoreInt32(&invoked, 0)
+	h := New(next, Config{Marker: "ping"})
+
+	v, _ := h.Handle(json.RawMessage(`{"ping":true}`), nil)
+	assert.True(t, v.(*Response).ColdStart)
+
+	v, _ = h.Handle(json.RawMessage(`{"ping":true}`), nil)
+	assert.False(t, v.(*Response).ColdStart)
+}
+
+func TestHandler_rule(t *testing.T) {
+	h := New(next, Config{
+		RuleARNs: []string{"arn:aws:events:us-east-1:123456789012:rule/warm"},
+	})
+
+	event := `{
+		"source": "aws.events",
+		"detail-type": "Scheduled Event",
+		"resources": ["arn:aws:events:us-east-1:123456789012:rule/warm"]
+	}`
+
+	v, err := h.Handle(json.RawMessage(event), nil)
+	assert.NoError(t, err)
+	assert.True(t, v.(*Response).Warm)
+
+	event = `{
+		"source": "aws.events",
+		"detail-type": "Scheduled Event",
+		"resources": ["arn:aws:events:us-east-1:123456789012:rule/other"]
+	}`
+
+	v, err = h.Handle(json.RawMessage(event), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "handled", v)
+}
+
+func TestHandler_fanout(t *testing.T) {
+	var calls int32
+	var child apex.Handler
+
+	invoker := InvokerFunc(func(function string, payload []byte) ([]byte, error) {
+		assert.Equal(t, "arn:aws:lambda:us-east-1:123456789012:function:app", function)
+		if atomic.AddInt32(&calls, 1) == 2 {
+			return nil, errors.New("throttled")
+		}
+		v, err := child.Handle(json.RawMessage(payload), nil)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	})
+
+	child = New(next, Config{Delay: 1})
+	h := New(next, Config{Invoker: invoker})
+
+	v, err := h.Handle(json.RawMessage(`{"warmer":true,"concurrency":4}`), &apex.Context{
+		InvokedFunctionARN: "arn:aws:lambda:us-east-1:123456789012:function:app",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls)
+	assert.Len(t, v.(*Response).Containers, 2)
+
+	// Limited concurrency
+	atomic.StoreInt32(&calls, 10)
+	h = New(next, Config{Invoker: invoker, MaxConcurrency: 3})
+
+	v, err = h.Handle(json.RawMessage(`{"warmer":true,"concurrency":5000}`), &apex.Context{
+		InvokedFunctionARN: "arn:aws:lambda:us-east-1:123456789012:function:app",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(12), calls)
+	assert.Len(t, v.(*Response).Containers, 2)
+}
@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi/apigatewaymanagementapiiface"
+)
+
+// ErrGone is returned for connections which are closed or unknown.
+var ErrGone = errors.New("websocket: connection gone")
+
+// Connections manages the connections of a WebSocket API.
+type Connections interface {
+	// PostToConnection sends data to the client of a connection.
+	PostToConnection(connectionID string, data []byte) error
+
+	// GetConnection returns information about a connection.
+	GetConnection(connectionID string) (*Connection, error)
+
+	// DeleteConnection closes a connection.
+	DeleteConnection(connectionID string) error
+}
+
+// Connection is information about a connection.
+type Connection struct {
+	ConnectedAt  time.Time `json:"connectedAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+	Identity     Identity  `json:"identity"`
+}
+
+// Client implements Connections using the @connections API of a stage,
+// through the API Gateway Management API client of the AWS SDK.
+type Client struct {
+	// Endpoint is the URL of the stage, such as
+	// "https://abc123.execute-api.us-east-1.amazonaws.com/production".
+	Endpoint string
+
+	// Config optionally configures the SDK client, such as its region,
+	// credentials or HTTP client. The region and credentials default to
+	// those of the Lambda environment.
+	Config *aws.Config
+
+	once sync.Once
+	api  apigatewaymanagementapiiface.ApiGatewayManagementApiAPI
+	err  error
+}
+
+// NewClient returns a client for the stage e was sent by.
+func NewClient(e *Event) *Client {
+	c := &Client{}
+	if rc := e.RequestContext; rc != nil {
+		c.Endpoint = "https://" + rc.DomainName + "/" + rc.Stage
+	}
+	return c
+}
+
+// PostToConnection implements Connections.
+func (c *Client) PostToConnection(connectionID string, data []byte) error {
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	_, err = api.PostToConnection(&apigatewaymanagementapi.PostToConnectionInput{
+		ConnectionId: aws.String(connectionID),
+		Data:         data,
+	})
+	return connectionError("POST", connectionID, err)
+}
+
+// GetConnection implements Connections.
+func (c *Client) GetConnection(connectionID string) (*Connection, error) {
+	api, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := api.GetConnection(&apigatewaymanagementapi.GetConnectionInput{
+		ConnectionId: aws.String(connectionID),
+	})
+	if err != nil {
+		return nil, connectionError("GET", connectionID, err)
+	}
+
+	conn := &Connection{
+		ConnectedAt:  aws.TimeValue(out.ConnectedAt),
+		LastActiveAt: aws.TimeValue(out.LastActiveAt),
+	}
+
+	if id := out.Identity; id != nil {
+		conn.Identity = Identity{
+			SourceIP:  aws.StringValue(id.SourceIp),
+			UserAgent: aws.StringValue(id.UserAgent),
+		}
+	}
+
+	return conn, nil
+}
+
+// DeleteConnection implements Connections.
+func (c *Client) DeleteConnection(connectionID string) error {
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	_, err = api.DeleteConnection(&apigatewaymanagementapi.DeleteConnectionInput{
+		ConnectionId: aws.String(connectionID),
+	})
+	return connectionError("DELETE", connectionID, err)
+}
+
+// client returns the SDK client of the endpoint, created once.
+func (c *Client) client() (apigatewaymanagementapiiface.ApiGatewayManagementApiAPI, error) {
+	c.once.Do(func() {
+		sess, err := session.NewSession(c.Config)
+		if err != nil {
+			c.err = fmt.Errorf("websocket: creating session: %s", err)
+			return
+		}
+
+		c.api = apigatewaymanagementapi.New(sess, aws.NewConfig().WithEndpoint(c.Endpoint))
+	})
+
+	return c.api, c.err
+}
+
+// connectionError returns ErrGone for connections which are closed or
+// unknown, otherwise err annotated with the method and connection.
+func connectionError(method, connectionID string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := err.(awserr.Error); ok && e.Code() == apigatewaymanagementapi.ErrCodeGoneException {
+		return ErrGone
+	}
+
+	return fmt.Errorf("websocket: %s %s: %s", method, connectionID, err)
+}
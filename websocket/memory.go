@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory Connections for offline testing, recording the
+// messages posted to each connection.
+type Memory struct {
+	mu          sync.Mutex
+	connections map[string]*Connection
+	messages    map[string][][]byte
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{
+		connections: make(map[string]*Connection),
+		messages:    make(map[string][][]byte),
+	}
+}
+
+// Connect adds the connection id.
+func (m *Memory) Connect(id string, identity Identity) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.connections[id] = &Connection{
+		ConnectedAt:  now,
+		LastActiveAt: now,
+		Identity:     identity,
+	}
+}
+
+// Messages returns the messages posted to the connection id.
+func (m *Memory) Messages(id string) [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages[id]
+}
+
+// PostToConnection implements Connections.
+func (m *Memory) PostToConnection(id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.connections[id]; !ok {
+		return ErrGone
+	}
+
+	m.messages[id] = append(m.messages[id], append([]byte(nil), data...))
+	return nil
+}
+
+// GetConnection implements Connections.
+func (m *Memory) GetConnection(id string) (*Connection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.connections[id]
+	if !ok {
+		return nil, ErrGone
+	}
+
+	conn := *c
+	return &conn, nil
+}
+
+// DeleteConnection implements Connections.
+func (m *Memory) DeleteConnection(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.connections[id]; !ok {
+		return ErrGone
+	}
+
+	delete(m.connections, id)
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package websocket provides structs for working with API Gateway WebSocket
+// API events, a route key router, and a client for the @connections API.
+package websocket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/apex/go-apex"
+)
+
+// Predefined route keys.
+const (
+	RouteConnect    = "$connect"
+	RouteDisconnect = "$disconnect"
+	RouteDefault    = "$default"
+)
+
+// Event types.
+const (
+	EventConnect    = "CONNECT"
+	EventMessage    = "MESSAGE"
+	EventDisconnect = "DISCONNECT"
+)
+
+// Event represents a WebSocket API event. Headers and query string
+// parameters are only present for $connect.
+type Event struct {
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	StageVariables                  map[string]string   `json:"stageVariables"`
+	RequestContext                  *RequestContext     `json:"requestContext"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+}
+
+// RequestContext represents the context of a WebSocket API event.
+type RequestContext struct {
+	RouteKey             string                 `json:"routeKey"`
+	EventType            string                 `json:"eventType"`
+	ConnectionID         string                 `json:"connectionId"`
+	ConnectedAt          int64                  `json:"connectedAt"`
+	MessageID            string                 `json:"messageId"`
+	MessageDirection     string                 `json:"messageDirection"`
+	ExtendedRequestID    string                 `json:"extendedRequestId"`
+	RequestID            string                 `json:"requestId"`
+	RequestTime          string                 `json:"requestTime"`
+	RequestTimeEpoch     int64                  `json:"requestTimeEpoch"`
+	DomainName           string                 `json:"domainName"`
+	Stage                string                 `json:"stage"`
+	APIID                string                 `json:"apiId"`
+	Identity             *Identity              `json:"identity"`
+	Authorizer           map[string]interface{} `json:"authorizer"`
+	DisconnectStatusCode int                    `json:"disconnectStatusCode"`
+	DisconnectReason     string                 `json:"disconnectReason"`
+}
+
+// Identity represents the client of a WebSocket API event.
+type Identity struct {
+	SourceIP  string `json:"sourceIp"`
+	UserAgent string `json:"userAgent"`
+}
+
+// Data returns the decoded message body.
+func (e *Event) Data() ([]byte, error) {
+	if e.IsBase64Encoded {
+		return base64.StdEncoding.DecodeString(e.Body)
+	}
+	return []byte(e.Body), nil
+}
+
+// routeKey returns the route key of the event.
+func (e *Event) routeKey() string {
+	if e.RequestContext == nil {
+		return ""
+	}
+	return e.RequestContext.RouteKey
+}
+
+// Response is returned to API Gateway. A status code other than 2xx rejects
+// a $connect request, while for other routes the body is sent to the client
+// when the route has a route response.
+type Response struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body,omitempty"`
+	IsBase64Encoded bool              `json:"isBase64Encoded,omitempty"`
+}
+
+// Handler handles WebSocket API events.
+type Handler interface {
+	HandleWebSocket(*Event, *apex.Context) (*Response, error)
+}
+
+// HandlerFunc unmarshals WebSocket API events before passing control. A nil
+// response is returned to API Gateway as a 200.
+type HandlerFunc func(*Event, *apex.Context) (*Response, error)
+
+// HandleWebSocket implements Handler.
+func (h HandlerFunc) HandleWebSocket(e *Event, ctx *apex.Context) (*Response, error) {
+	return h(e, ctx)
+}
+
+// Handle implements apex.Handler.
+func (h HandlerFunc) Handle(data json.RawMessage, ctx *apex.Context) (interface{}, error) {
+	var event Event
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+
+	res, err := h(&event, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		res = &Response{StatusCode: 200}
+	}
+
+	return res, nil
+}
+
+// HandleFunc handles WebSocket API events with callback function.
+func HandleFunc(h HandlerFunc) {
+	apex.Handle(h)
+}
+
+// Handle WebSocket API events with handler.
+func Handle(h Handler) {
+	HandleFunc(HandlerFunc(h.HandleWebSocket))
+}
+
+// Router dispatches events to the handler of their route key, such as
+// "$connect" or a custom route selected by the route selection expression of
+// the API. Events without a handler are passed to the $default handler.
+type Router struct {
+	mu     sync.RWMutex
+	routes map[string]Handler
+}
+
+// NewRouter returns a new router.
+func NewRouter() *Router {
+	return &Router{
+		routes: make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for routeKey.
+func (r *Router) Handle(routeKey string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[routeKey] = h
+}
+
+// HandleFunc registers the handler function for routeKey.
+func (r *Router) HandleFunc(routeKey string, h HandlerFunc) {
+	r.Handle(routeKey, h)
+}
+
+// HandleWebSocket implements Handler.
+func (r *Router) HandleWebSocket(e *Event, ctx *apex.Context) (*Response, error) {
+	key := e.routeKey()
+
+	r.mu.RLock()
+	h, ok := r.routes[key]
+	if !ok {
+		h, ok = r.routes[RouteDefault]
+	}
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("websocket: no handler for route %q", key)
+	}
+
+	return h.HandleWebSocket(e, ctx)
+}
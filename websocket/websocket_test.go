@@ -0,0 +1,170 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apex/go-apex"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+var connectEvent = `{
+	"headers": {"Host": "abc123.execute-api.us-east-1.amazonaws.com"},
+	"queryStringParameters": {"room": "general"},
+	"requestContext": {
+		"routeKey": "$connect",
+		"eventType": "CONNECT",
+		"connectionId": "L0SM9cOFvHcCIhw=",
+		"connectedAt": 1547557733712,
+		"domainName": "abc123.execute-api.us-east-1.amazonaws.com",
+		"stage": "production",
+		"apiId": "abc123",
+		"identity": {"sourceIp": "192.0.2.1", "userAgent": "wscat"}
+	},
+	"isBase64Encoded": false
+}`
+
+func TestHandlerFunc_Handle(t *testing.T) {
+	h := HandlerFunc(func(e *Event, ctx *apex.Context) (*Response, error) {
+		assert.Equal(t, EventConnect, e.RequestContext.EventType)
+		assert.Equal(t, "L0SM9cOFvHcCIhw=", e.RequestContext.ConnectionID)
+		assert.Equal(t, "general", e.QueryStringParameters["room"])
+		assert.Equal(t, "192.0.2.1", e.RequestContext.Identity.SourceIP)
+		return nil, nil
+	})
+
+	v, err := h.Handle(json.RawMessage(connectEvent), &apex.Context{})
+	assert.NoError(t, err)
+	assert.Equal(t, &Response{StatusCode: 200}, v)
+}
+
+func TestEvent_Data(t *testing.T) {
+	e := &Event{Body: "aGk=", IsBase64Encoded: true}
+	b, err := e.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", string(b))
+
+	e = &Event{Body: `{"action":"send"}`}
+	b, err = e.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"action":"send"}`, string(b))
+}
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+
+	route := func(name string) HandlerFunc {
+		return func(e *Event, ctx *apex.Context) (*Response, error) {
+			return &Response{StatusCode: 200, Body: name}, nil
+		}
+	}
+
+	event := func(key string) *Event {
+		return &Event{RequestContext: &RequestContext{RouteKey: key}}
+	}
+
+	r.HandleFunc(RouteConnect, route("connect"))
+	r.HandleFunc("send", route("send"))
+
+	res, err := r.HandleWebSocket(event("$connect"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "connect", res.Body)
+
+	res, err = r.HandleWebSocket(event("send"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "send", res.Body)
+
+	_, err = r.HandleWebSocket(event("typing"), nil)
+	assert.EqualError(t, err, `websocket: no handler for route "typing"`)
+
+	r.HandleFunc(RouteDefault, route("default"))
+
+	res, err = r.HandleWebSocket(event("typing"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", res.Body)
+}
+
+func TestClient(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, string(b))
+
+		switch {
+		case strings.HasSuffix(r.URL.EscapedPath(), "/gone"):
+			w.Header().Set("X-Amzn-Errortype", "GoneException")
+			w.WriteHeader(http.StatusGone)
+		case r.Method == "GET":
+			w.Write([]byte(`{"connectedAt":"2019-01-15T13:08:53.712Z","lastActiveAt":"2019-01-15T13:09:02.000Z","identity":{"sourceIp":"192.0.2.1","userAgent":"wscat"}}`))
+		case r.Method == "DELETE":
+			w.Header().Set("X-Amzn-Errortype", "ForbiddenException")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Forbidden"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		Endpoint: srv.URL + "/production",
+		Config: aws.NewConfig().
+			WithRegion("us-east-1").
+			WithCredentials(credentials.NewStaticCredentials("AKID", "secret", "token")).
+			WithMaxRetries(0),
+	}
+
+	err := c.PostToConnection("L0SM9cOFvHcCIhw=", []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", requests[0].Method)
+	assert.Equal(t, "/production/@connections/L0SM9cOFvHcCIhw%3D", requests[0].URL.EscapedPath())
+	assert.Equal(t, "hello", bodies[0])
+	assert.Equal(t, "token", requests[0].Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "Credential=AKID/")
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "/us-east-1/execute-api/aws4_request")
+
+	conn, err := c.GetConnection("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", conn.Identity.SourceIP)
+	assert.Equal(t, 2019, conn.ConnectedAt.Year())
+
+	err = c.DeleteConnection("abc")
+	assert.Contains(t, err.Error(), "websocket: DELETE abc: ForbiddenException: Forbidden")
+
+	err = c.PostToConnection("gone", []byte("hello"))
+	assert.Equal(t, ErrGone, err)
+}
+
+func TestNewClient(t *testing.T) {
+	var e Event
+	assert.NoError(t, json.Unmarshal([]byte(connectEvent), &e))
+	assert.Equal(t, "https://abc123.execute-api.us-east-1.amazonaws.com/production", NewClient(&e).Endpoint)
+}
+
+func TestMemory(t *testing.T) {
+	var conns Connections = NewMemory()
+	m := conns.(*Memory)
+
+	assert.Equal(t, ErrGone, conns.PostToConnection("a", []byte("hi")))
+
+	m.Connect("a", Identity{SourceIP: "192.0.2.1"})
+	assert.NoError(t, conns.PostToConnection("a", []byte("hi")))
+	assert.NoError(t, conns.PostToConnection("a", []byte("there")))
+	assert.Equal(t, [][]byte{[]byte("hi"), []byte("there")}, m.Messages("a"))
+
+	conn, err := conns.GetConnection("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1", conn.Identity.SourceIP)
+
+	assert.NoError(t, conns.DeleteConnection("a"))
+	assert.Equal(t, ErrGone, conns.DeleteConnection("a"))
+	_, err = conns.GetConnection("a")
+	assert.Equal(t, ErrGone, err)
+}